@@ -0,0 +1,147 @@
+package clients
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	// Requests are let through as normal
+	BreakerClosed BreakerState = "closed"
+	// Requests fail immediately until the cooldown has passed
+	BreakerOpen BreakerState = "open"
+	// A single request is let through to decide whether to close the breaker again
+	BreakerHalfOpen BreakerState = "half-open"
+
+	// Consecutive failures after which a breaker opens
+	defaultBreakerThreshold = 5
+	// How long a breaker stays open before letting a request through
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// A Breaker stops requests to a client that keeps failing so that pages of posts are not held up
+// waiting on it, and lets a request through every cooldown to find out whether it has recovered.
+type Breaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// Whether the half open request is in flight
+	probing bool
+}
+
+// The state of a breaker as reported by the health endpoint
+type BreakerStatus struct {
+	Client   string       `json:"client"`
+	State    BreakerState `json:"state"`
+	Failures int          `json:"consecutive-failures"`
+	OpenedAt *time.Time   `json:"opened-at,omitempty"`
+}
+
+// Breakers of every client that core has created an http client for, by client name
+var (
+	breakersLock sync.Mutex
+	breakers     = make(map[string]*Breaker)
+)
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now, state: BreakerClosed}
+}
+
+// Produces the breaker shared by every http client of the named client
+func breakerFor(name string) *Breaker {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+
+	b, ok := breakers[name]
+	if !ok {
+		b = NewBreaker(name, defaultBreakerThreshold, defaultBreakerCooldown)
+		breakers[name] = b
+	}
+	return b
+}
+
+// Produces the status of every client breaker sorted by client name
+func Breakers() []BreakerStatus {
+	breakersLock.Lock()
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.Status())
+	}
+	breakersLock.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Client < statuses[j].Client })
+	return statuses
+}
+
+// Reports whether a request may be made, every allowed request must be followed by Success, Failure or release
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Gives up on an allowed request without counting it as a success or failure
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{Client: b.name, State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
@@ -1,18 +1,58 @@
 package clients
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/iced-mocha/core/metrics"
 	"github.com/iced-mocha/shared/models"
 )
 
 type Client interface {
-	// returns a function which will return the next page of posts for the
+	// returns a generator which will produce the next page of posts for the
 	// content provider
-	GetPageGenerator(user models.User) (func() []models.Post, error)
-	GetDefaultPageGenerator() (func() []models.Post, error)
+	GetPageGenerator(user models.User) (PageGenerator, error)
+	GetDefaultPageGenerator() (PageGenerator, error)
+	// recreates a page generator from a cursor previously produced by one of
+	// this clients generators
+	ResumePageGenerator(user models.User, cursor Cursor) (PageGenerator, error)
 	Name() string
 	Weight() float64
+	// Checks that the client service can be reached
+	Ping(ctx context.Context) error
+}
+
+// A PageGenerator produces successive pages of posts for a single content provider.
+// Its position can be captured as a Cursor so that any instance of core can resume it.
+type PageGenerator interface {
+	// Fetches the next page of posts, giving up once ctx is done. An empty page means there
+	// are no more posts, a failed page can be retried by calling NextPage again.
+	NextPage(ctx context.Context) ([]models.Post, error)
+	Cursor() Cursor
+}
+
+// Cursor is a serializable snapshot of where a content provider is within its feed.
+// Client, Group and NextURL are maintained by the page generator, Offset and
+// SequenceLength are filled in by the ranking package.
+type Cursor struct {
+	Client string `json:"c"`
+	// Only used by rss where a single client produces a provider per group
+	Group string `json:"g,omitempty"`
+	// The upstream url that will produce the next page of posts
+	NextURL string `json:"n,omitempty"`
+	// The number of posts already consumed from the page at NextURL
+	Offset         int `json:"o,omitempty"`
+	SequenceLength int `json:"s,omitempty"`
+}
+
+// Produces the name of the source the cursor reads from, the client name or rss/<group> for rss groups
+func (c Cursor) Source() string {
+	if c.Group != "" {
+		return c.Client + "/" + c.Group
+	}
+	return c.Client
 }
 
 // Wrapper for the response from a post client
@@ -22,6 +62,73 @@ type PostResponse struct {
 	Err     error
 }
 
+// URLPageGenerator is a PageGenerator for clients that page through their posts by
+// following the next url returned by the upstream service
+type URLPageGenerator struct {
+	cursor Cursor
+	fetch  func(ctx context.Context, url string) PostResponse
+}
+
+func NewURLPageGenerator(cursor Cursor, fetch func(ctx context.Context, url string) PostResponse) *URLPageGenerator {
+	return &URLPageGenerator{cursor: cursor, fetch: fetch}
+}
+
+func (g *URLPageGenerator) NextPage(ctx context.Context) ([]models.Post, error) {
+	if g.cursor.NextURL == "" {
+		return []models.Post{}, nil
+	}
+
+	start := time.Now()
+	resp := g.fetch(ctx, g.cursor.NextURL)
+	metrics.ObserveClientFetch(g.cursor.Client, time.Since(start), resp.Err)
+	if resp.Err != nil {
+		// The cursor is left at the failed page so that it is retried by the next call
+		return nil, fmt.Errorf("error getting %v page: %v", g.cursor.Client, resp.Err)
+	}
+
+	g.cursor.NextURL = resp.NextURL
+	return resp.Posts, nil
+}
+
+func (g *URLPageGenerator) Cursor() Cursor {
+	return g.cursor
+}
+
+// Produces a generator that never has any posts, used for clients that cannot provide posts for a user
+func EmptyPageGenerator(name string) PageGenerator {
+	return NewURLPageGenerator(Cursor{Client: name}, nil)
+}
+
+// Performs a GET request for url with client that is cancelled once ctx is done
+func Get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req.WithContext(ctx))
+}
+
+// Checks that the service at baseURL responds with anything other than a server error. Pings are made
+// once, without the retries of client's Transport, and do not count towards the breaker of the client.
+func Ping(ctx context.Context, client *http.Client, baseURL string) error {
+	if t, ok := client.Transport.(*Transport); ok {
+		direct := *client
+		direct.Transport = t.Base
+		client = &direct
+	}
+
+	resp, err := Get(ctx, client, baseURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("received status code %v", resp.StatusCode)
+	}
+	return nil
+}
+
 type InvalidAuth struct {
 	ClientName   string
 	ErrorMessage string
@@ -0,0 +1,61 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ClientsTestSuite) TestURLPageGenerator() {
+	fail := true
+	g := NewURLPageGenerator(Cursor{Client: "test-client", NextURL: "page-0"}, func(ctx context.Context, url string) PostResponse {
+		if fail {
+			return PostResponse{Err: errors.New("unavailable")}
+		}
+		return PostResponse{Posts: []models.Post{{ID: url}}, NextURL: ""}
+	})
+
+	// A failed page is retried by the next call
+	posts, err := g.NextPage(context.Background())
+	suite.NotNil(err)
+	suite.Empty(posts)
+	suite.Equal("page-0", g.Cursor().NextURL)
+
+	fail = false
+	posts, err = g.NextPage(context.Background())
+	suite.Nil(err)
+	suite.Equal([]models.Post{{ID: "page-0"}}, posts)
+	suite.Equal("", g.Cursor().NextURL)
+
+	// Once there are no more pages the generator produces empty pages
+	posts, err = g.NextPage(context.Background())
+	suite.Nil(err)
+	suite.Empty(posts)
+}
+
+func (suite *ClientsTestSuite) TestGetCancelled() {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Get(ctx, server.Client(), server.URL)
+	suite.NotNil(err)
+}
+
+func TestClientsTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientsTestSuite))
+}
@@ -1,70 +1,97 @@
 package facebook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 
 	"github.com/iced-mocha/core/clients"
 	"github.com/iced-mocha/shared/models"
 )
 
+const (
+	name          = "facebook"
+	DefaultWeight = 10.0
+)
+
 type Facebook struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
+	client *http.Client
 }
 
-func New(host string, port int) *Facebook {
-	return &Facebook{Host: host, Port: port}
+func New(conf clients.Config) (*Facebook, error) {
+	client, err := clients.NewHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Facebook{conf: conf, client: client}, nil
 }
 
-func (f *Facebook) GetDefaultPageGenerator() (func() []models.Post, error) {
-	defaultPosts := func() []models.Post {
-		return []models.Post{}
-	}
+func (f *Facebook) GetDefaultPageGenerator() (clients.PageGenerator, error) {
+	return clients.EmptyPageGenerator(f.Name()), nil
+}
 
-	return defaultPosts, nil
+func (f *Facebook) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
+	nextFBURL := f.conf.BaseURL() + "/v1/posts"
+	return f.ResumePageGenerator(user, clients.Cursor{Client: f.Name(), NextURL: nextFBURL})
 }
 
-func (f *Facebook) GetPageGenerator(user models.User) (func() []models.Post, error) {
+func (f *Facebook) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
 	if user.FacebookAuthToken == "" {
 		return nil, clients.InvalidAuth{f.Name(), "empty auth token"}
 	}
 
-	nextFBURL := fmt.Sprintf("http://%v:%v/v1/posts?fb_token=%v", f.Host, f.Port, user.FacebookAuthToken)
-	getNextFBPage := func() []models.Post {
-		if nextFBURL == "" {
-			return make([]models.Post, 0)
-		}
-
-		resp := posts(nextFBURL)
-
-		if resp.Err == nil {
-			nextFBURL = resp.NextURL
-			return resp.Posts
-		} else {
-			log.Printf("error getting fb page %v\n", resp.Err)
-			return make([]models.Post, 0)
-		}
+	// The users token is only added at request time so that it never ends up in a page token
+	getNextFBPage := func(ctx context.Context, url string) clients.PostResponse {
+		resp := f.posts(ctx, withToken(url, user.FacebookAuthToken))
+		resp.NextURL = withToken(resp.NextURL, "")
+		return resp
 	}
 
-	return getNextFBPage, nil
+	return clients.NewURLPageGenerator(cursor, getNextFBPage), nil
+}
+
+// Sets the fb_token query parameter of the given url, an empty token removes it
+func withToken(rawURL, token string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	if token == "" {
+		q.Del("fb_token")
+	} else {
+		q.Set("fb_token", token)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
 }
 
 func (f *Facebook) Name() string {
-	return "facebook"
+	return name
 }
 
 func (f *Facebook) Weight() float64 {
 	return f.weight
 }
 
-func posts(url string) clients.PostResponse {
+func (f *Facebook) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, f.client, f.conf.BaseURL())
+}
+
+func (f *Facebook) posts(ctx context.Context, url string) clients.PostResponse {
 	var fbRespBody models.ClientResp
 	var fbPosts = make([]models.Post, 0)
-	fbResp, err := http.Get(url)
+	fbResp, err := clients.Get(ctx, f.client, url)
 	if err != nil {
 		return clients.PostResponse{fbPosts, "", fmt.Errorf("Unable to get posts from facebook: %v", err)}
 	}
@@ -78,3 +105,7 @@ func posts(url string) clients.PostResponse {
 
 	return clients.PostResponse{fbPosts, fbRespBody.NextURL, nil}
 }
+
+func init() {
+	clients.Register(name, func(conf clients.Config) (clients.Client, error) { return New(conf) }, DefaultWeight)
+}
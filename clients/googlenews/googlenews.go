@@ -1,62 +1,66 @@
 package googlenews
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/logging"
 	"github.com/iced-mocha/shared/models"
 )
 
+const (
+	name          = "google-news"
+	DefaultWeight = 20.0
+)
+
 type GoogleNews struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
+	client *http.Client
 }
 
-func New(host string, port int) *GoogleNews {
-	return &GoogleNews{Host: host, Port: port}
+func New(conf clients.Config) (*GoogleNews, error) {
+	client, err := clients.NewHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleNews{conf: conf, client: client}, nil
 }
 
-func (g *GoogleNews) GetDefaultPageGenerator() (func() []models.Post, error) {
-	called := false
-	getNextPage := func() []models.Post {
-		// google news is not paginated, so if we have gotten the first page,
-		// we have gotten all the pages
-		if called {
-			return make([]models.Post, 0)
-		}
-		called = true
-
-		resp := g.posts()
-		if resp.Err == nil {
-			return resp.Posts
-		} else {
-			log.Printf("error getting gn page %v\n", resp.Err)
-			return make([]models.Post, 0)
-		}
-	}
-	return getNextPage, nil
+// google news is not paginated, so once we have gotten the first page the
+// cursor has no next url and we have gotten all the pages
+func (g *GoogleNews) GetDefaultPageGenerator() (clients.PageGenerator, error) {
+	nextURL := g.conf.BaseURL() + "/v1/posts?count=20"
+	return g.ResumePageGenerator(models.User{}, clients.Cursor{Client: g.Name(), NextURL: nextURL})
 }
 
-func (g *GoogleNews) GetPageGenerator(user models.User) (func() []models.Post, error) {
+func (g *GoogleNews) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
 	return g.GetDefaultPageGenerator()
 }
 
+func (g *GoogleNews) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
+	return clients.NewURLPageGenerator(cursor, g.posts), nil
+}
+
 func (g *GoogleNews) Name() string {
-	return "google-news"
+	return name
 }
 
 func (g *GoogleNews) Weight() float64 {
 	return g.weight
 }
 
-func (g *GoogleNews) posts() clients.PostResponse {
+func (g *GoogleNews) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, g.client, g.conf.BaseURL())
+}
+
+func (g *GoogleNews) posts(ctx context.Context, url string) clients.PostResponse {
 	gnPosts := make([]models.Post, 0, 0)
 
-	gnResp, err := http.Get(fmt.Sprintf("http://%v:%v/v1/posts?count=20", g.Host, g.Port))
+	gnResp, err := clients.Get(ctx, g.client, url)
 	if err != nil {
 		return clients.PostResponse{gnPosts, "", fmt.Errorf("Unable to fetch posts from google news: %v", err)}
 	}
@@ -67,6 +71,10 @@ func (g *GoogleNews) posts() clients.PostResponse {
 		return clients.PostResponse{gnPosts, "", fmt.Errorf("Unable to decode response from google-news: %v", err)}
 	}
 
-	log.Println("Successfully retrieved posts from googlenews")
+	logging.FromContext(ctx).Debugf("Successfully retrieved posts from googlenews")
 	return clients.PostResponse{gnPosts, "", nil}
 }
+
+func init() {
+	clients.Register(name, func(conf clients.Config) (clients.Client, error) { return New(conf) }, DefaultWeight)
+}
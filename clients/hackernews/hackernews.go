@@ -1,60 +1,63 @@
 package hackernews
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/iced-mocha/core/clients"
 	"github.com/iced-mocha/shared/models"
 )
 
+const (
+	name          = "hacker-news"
+	DefaultWeight = 20.0
+)
+
 type HackerNews struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
+	client *http.Client
 }
 
-func New(host string, port int) *HackerNews {
-	return &HackerNews{Host: host, Port: port}
+func New(conf clients.Config) (*HackerNews, error) {
+	client, err := clients.NewHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &HackerNews{conf: conf, client: client}, nil
 }
 
-func (h *HackerNews) GetDefaultPageGenerator() (func() []models.Post, error) {
+func (h *HackerNews) GetDefaultPageGenerator() (clients.PageGenerator, error) {
 	return h.GetPageGenerator(models.User{})
 }
 
-func (h *HackerNews) GetPageGenerator(user models.User) (func() []models.Post, error) {
-	nextURL := fmt.Sprintf("http://%v:%v/v1/posts?count=20", h.Host, h.Port)
-	getNextPage := func() []models.Post {
-		if nextURL == "" {
-			return []models.Post{}
-		}
-		resp := h.getPosts(nextURL)
-		if resp.Err == nil {
-			nextURL = resp.NextURL
-			return resp.Posts
-		} else {
-			nextURL = ""
-			log.Printf("error getting hn page %v\n", resp.Err)
-			return []models.Post{}
-		}
-	}
-	return getNextPage, nil
+func (h *HackerNews) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
+	nextURL := h.conf.BaseURL() + "/v1/posts?count=20"
+	return h.ResumePageGenerator(user, clients.Cursor{Client: h.Name(), NextURL: nextURL})
+}
+
+func (h *HackerNews) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
+	return clients.NewURLPageGenerator(cursor, h.getPosts), nil
 }
 
 func (h *HackerNews) Name() string {
-	return "hacker-news"
+	return name
 }
 
 func (h *HackerNews) Weight() float64 {
 	return h.weight
 }
 
-func (h *HackerNews) getPosts(url string) clients.PostResponse {
+func (h *HackerNews) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, h.client, h.conf.BaseURL())
+}
+
+func (h *HackerNews) getPosts(ctx context.Context, url string) clients.PostResponse {
 	hnPosts := make([]models.Post, 0)
 
-	hnResp, err := http.Get(url)
+	hnResp, err := clients.Get(ctx, h.client, url)
 	if err != nil {
 		return clients.PostResponse{hnPosts, "", fmt.Errorf("Unable to fetch posts from hacker news: %v", err)}
 	}
@@ -68,3 +71,7 @@ func (h *HackerNews) getPosts(url string) clients.PostResponse {
 
 	return clients.PostResponse{hnRespBody.Posts, hnRespBody.NextURL, nil}
 }
+
+func init() {
+	clients.Register(name, func(conf clients.Config) (clients.Client, error) { return New(conf) }, DefaultWeight)
+}
@@ -2,85 +2,75 @@ package reddit
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 
 	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/logging"
 	"github.com/iced-mocha/shared/models"
 )
 
+const (
+	name          = "reddit"
+	DefaultWeight = 20.0
+)
+
 type Reddit struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
 	client *http.Client
 }
 
-func New(host string, port int) *Reddit {
-	// Only needed to test locally to allow for use of self signed certs
-	cert, err := ioutil.ReadFile("/usr/local/etc/ssl/certs/reddit.crt")
+func New(conf clients.Config) (*Reddit, error) {
+	client, err := clients.NewHTTPClient(conf)
 	if err != nil {
-		log.Fatal(err)
-	}
-	certPool := x509.NewCertPool()
-	certPool.AppendCertsFromPEM(cert)
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: certPool,
-			},
-		},
+		return nil, err
 	}
-	return &Reddit{Host: host, Port: port, client: client}
+	return &Reddit{conf: conf, client: client}, nil
 }
 
 func (r *Reddit) GetStartingURL(user models.User) string {
 	if user.RedditUsername == "" {
-		log.Printf("Unauthenticated user detected using default reddit page generator.")
-		return fmt.Sprintf("https://%v:%v/v1/posts", r.Host, r.Port)
+		logging.Debugf("Unauthenticated user detected using default reddit page generator")
+		return r.conf.BaseURL() + "/v1/posts"
 	}
 
-	log.Printf("Getting reddit page generator for user: %v", user.Username)
-	return fmt.Sprintf("https://%v:%v/v1/%v/posts", r.Host, r.Port, user.RedditUsername)
+	logging.Debugf("Getting reddit page generator for user: %v", user.Username)
+	return fmt.Sprintf("%v/v1/%v/posts", r.conf.BaseURL(), user.RedditUsername)
 }
 
-func (r *Reddit) GetDefaultPageGenerator() (func() []models.Post, error) {
+func (r *Reddit) GetDefaultPageGenerator() (clients.PageGenerator, error) {
 	return r.GetPageGenerator(models.User{})
 }
 
-func (r *Reddit) GetPageGenerator(user models.User) (func() []models.Post, error) {
-	nextURL := r.GetStartingURL(user)
-	getNextPage := func() []models.Post {
-		resp := r.getPosts(nextURL, user.RedditAuthToken, user.RedditRefreshToken)
-		if resp.Err != nil {
-			nextURL = ""
-			log.Printf("Error getting reddit page %v", resp.Err)
-			return []models.Post{}
-		}
-
-		// Next URL is specified by reddit client
-		nextURL = resp.NextURL
-		return resp.Posts
+func (r *Reddit) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
+	return r.ResumePageGenerator(user, clients.Cursor{Client: r.Name(), NextURL: r.GetStartingURL(user)})
+}
+
+func (r *Reddit) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
+	getPage := func(ctx context.Context, url string) clients.PostResponse {
+		return r.getPosts(ctx, url, user.RedditAuthToken, user.RedditRefreshToken)
 	}
 
-	return getNextPage, nil
+	return clients.NewURLPageGenerator(cursor, getPage), nil
 }
 
 func (r *Reddit) Name() string {
-	return "reddit"
+	return name
 }
 
 func (r *Reddit) Weight() float64 {
 	return r.weight
 }
 
-func (r *Reddit) getPosts(url, redditToken, refreshToken string) clients.PostResponse {
+func (r *Reddit) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, r.client, r.conf.BaseURL())
+}
+
+func (r *Reddit) getPosts(ctx context.Context, url, redditToken, refreshToken string) clients.PostResponse {
 	posts := []models.Post{}
 
 	// TODO Reddit-Clients GetPosts endpoint should accept request w/o these
@@ -91,8 +81,9 @@ func (r *Reddit) getPosts(url, redditToken, refreshToken string) clients.PostRes
 		return clients.PostResponse{posts, "", err}
 	}
 
-	log.Printf("Attemping to get reddit page with url: %v", url)
-	resp, err := r.client.Do(req)
+	logger := logging.FromContext(ctx)
+	logger.Debugf("Attempting to get reddit page with url: %v", url)
+	resp, err := r.client.Do(req.WithContext(ctx))
 	if err != nil {
 		return clients.PostResponse{posts, "", fmt.Errorf("Unable to get posts from reddit: %v", err)}
 	}
@@ -111,8 +102,12 @@ func (r *Reddit) getPosts(url, redditToken, refreshToken string) clients.PostRes
 	if json.Unmarshal(contents, &clientResp); err != nil {
 		return clients.PostResponse{posts, "", fmt.Errorf("Unable to decode posts from Reddit: %v", err)}
 	}
-	log.Printf("Assigning next URL: %v", clientResp.NextURL)
+	logger.Debugf("Assigning next URL: %v", clientResp.NextURL)
 
-	log.Println("Successfully retrieved posts from reddit")
+	logger.Debugf("Successfully retrieved posts from reddit")
 	return clients.PostResponse{clientResp.Posts, clientResp.NextURL, nil}
 }
+
+func init() {
+	clients.Register(name, func(conf clients.Config) (clients.Client, error) { return New(conf) }, DefaultWeight)
+}
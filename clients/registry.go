@@ -0,0 +1,180 @@
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/iced-mocha/core/config"
+)
+
+// Creates a client from its configuration
+type Factory func(conf Config) (Client, error)
+
+type registration struct {
+	factory       Factory
+	defaultWeight float64
+}
+
+// Various clients that are available to core -- Maps client names to how to create them.
+// Clients register themselves in their init functions, much like sessions providers do.
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]registration)
+)
+
+// Describes how core reaches a client, read from the clients section of our configuration. i.e
+//
+//	clients:
+//	  reddit:
+//	    host: "reddit-client"
+//	    port: 3001
+//	    tls:
+//	      enabled: true
+//	      ca-cert: "/usr/local/etc/ssl/certs/reddit.crt"
+type Config struct {
+	Name    string
+	Enabled bool
+	Host    string
+	Port    int
+	TLS     TLSConfig
+}
+
+type TLSConfig struct {
+	Enabled bool
+	// Path to a certificate to trust, only needed for clients using self signed certs
+	CACert string
+}
+
+// Produces the url all requests to the client are made relative to
+func (c Config) BaseURL() string {
+	scheme := "http"
+	if c.TLS.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%v://%v:%v", scheme, c.Host, c.Port)
+}
+
+// Register makes a client available by the provided name along with the weight its posts
+// get for users that have not chosen one.
+func Register(name string, factory Factory, defaultWeight float64) error {
+	if factory == nil {
+		return fmt.Errorf("factory for client %v is nil", name)
+	} else if name == "" {
+		return errors.New("provided name cannot be empty")
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[name] = registration{factory: factory, defaultWeight: defaultWeight}
+	return nil
+}
+
+// Produces the names of all registered clients in sorted order
+func Registered() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Produces the default weight of the named client, unknown clients have no weight
+func DefaultWeight(name string) float64 {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return registry[name].defaultWeight
+}
+
+// Creates the client registered under conf.Name
+func New(conf Config) (Client, error) {
+	registryLock.RLock()
+	r, ok := registry[conf.Name]
+	registryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("requested unknown client. %v not registered", conf.Name)
+	}
+
+	return r.factory(conf)
+}
+
+// Reads the configuration for the named client from clients.<name>. Clients are enabled unless
+// they set enabled: false, and only use tls when it is enabled.
+func ReadConfig(conf config.Config, name string) (Config, error) {
+	c := Config{Name: name, Enabled: true}
+	prefix := "clients." + name + "."
+
+	var err error
+	if c.Host, err = conf.GetString(prefix + "host"); err != nil {
+		return c, err
+	}
+	if c.Port, err = conf.GetInt(prefix + "port"); err != nil {
+		return c, err
+	}
+
+	// The remaining keys are optional
+	if enabled, err := conf.GetBool(prefix + "enabled"); err == nil {
+		c.Enabled = enabled
+	}
+	if enabled, err := conf.GetBool(prefix + "tls.enabled"); err == nil {
+		c.TLS.Enabled = enabled
+	}
+	if caCert, err := conf.GetString(prefix + "tls.ca-cert"); err == nil {
+		c.TLS.CACert = caCert
+	}
+
+	return c, nil
+}
+
+// Reads the configuration of every client listed in the clients section
+func ReadConfigs(conf config.Config) ([]Config, error) {
+	names, err := conf.GetKeys("clients")
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]Config, 0, len(names))
+	for _, name := range names {
+		c, err := ReadConfig(conf, name)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid configuration for client %v: %v", name, err)
+		}
+		configs = append(configs, c)
+	}
+
+	return configs, nil
+}
+
+// Creates an http client for talking to the client described by conf. Requests are retried
+// and go through the circuit breaker of the client.
+func NewHTTPClient(conf Config) (*http.Client, error) {
+	if !conf.TLS.Enabled || conf.TLS.CACert == "" {
+		return &http.Client{Transport: NewTransport(conf.Name, nil)}, nil
+	}
+
+	// Only needed to allow for use of self signed certs
+	cert, err := ioutil.ReadFile(conf.TLS.CACert)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	certPool.AppendCertsFromPEM(cert)
+
+	client := &http.Client{
+		Transport: NewTransport(conf.Name, &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: certPool,
+			},
+		}),
+	}
+	return client, nil
+}
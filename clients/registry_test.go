@@ -0,0 +1,132 @@
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/iced-mocha/core/config/yaml"
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testYaml = `
+---
+clients:
+  test-client:
+    host: localhost
+    port: 5000
+  disabled-client:
+    enabled: false
+    host: localhost
+    port: 5001
+  tls-client:
+    host: example.com
+    port: 443
+    tls:
+      enabled: true
+      ca-cert: /tmp/doesnotexist.crt
+`
+	missingPortYaml = `
+---
+clients:
+  test-client:
+    host: localhost
+`
+)
+
+type testClient struct {
+	conf Config
+}
+
+func (t *testClient) GetPageGenerator(user models.User) (PageGenerator, error) {
+	return EmptyPageGenerator(t.Name()), nil
+}
+
+func (t *testClient) GetDefaultPageGenerator() (PageGenerator, error) {
+	return EmptyPageGenerator(t.Name()), nil
+}
+
+func (t *testClient) ResumePageGenerator(user models.User, cursor Cursor) (PageGenerator, error) {
+	return EmptyPageGenerator(t.Name()), nil
+}
+
+func (t *testClient) Name() string {
+	return t.conf.Name
+}
+
+func (t *testClient) Weight() float64 {
+	return 0
+}
+
+func (t *testClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+type RegistryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RegistryTestSuite) SetupSuite() {
+	// Disable logging while testing
+	log.SetOutput(ioutil.Discard)
+
+	suite.Nil(Register("test-client", func(conf Config) (Client, error) { return &testClient{conf}, nil }, 15.0))
+}
+
+// Helper function for loading yaml contents as configuration
+func (suite *RegistryTestSuite) readYaml(contents string) *yaml.Yaml {
+	tmpfile, err := ioutil.TempFile("", "clients")
+	suite.Nil(err)
+	defer os.Remove(tmpfile.Name())
+
+	_, err = tmpfile.Write([]byte(contents))
+	suite.Nil(err)
+	suite.Nil(tmpfile.Close())
+
+	conf, err := yaml.New(tmpfile.Name())
+	suite.Nil(err)
+	return conf
+}
+
+func (suite *RegistryTestSuite) TestRegister() {
+	suite.NotNil(Register("test-client", nil, 0))
+	suite.Contains(Registered(), "test-client")
+	suite.Equal(15.0, DefaultWeight("test-client"))
+	suite.Equal(0.0, DefaultWeight("unknown-client"))
+}
+
+func (suite *RegistryTestSuite) TestReadConfigs() {
+	configs, err := ReadConfigs(suite.readYaml(testYaml))
+	suite.Nil(err)
+	suite.Equal([]Config{
+		{Name: "disabled-client", Enabled: false, Host: "localhost", Port: 5001},
+		{Name: "test-client", Enabled: true, Host: "localhost", Port: 5000},
+		{Name: "tls-client", Enabled: true, Host: "example.com", Port: 443, TLS: TLSConfig{Enabled: true, CACert: "/tmp/doesnotexist.crt"}},
+	}, configs)
+
+	suite.Equal("http://localhost:5000", configs[1].BaseURL())
+	suite.Equal("https://example.com:443", configs[2].BaseURL())
+
+	_, err = NewHTTPClient(configs[2])
+	suite.NotNil(err)
+
+	_, err = ReadConfigs(suite.readYaml(missingPortYaml))
+	suite.NotNil(err)
+}
+
+func (suite *RegistryTestSuite) TestNew() {
+	c, err := New(Config{Name: "test-client", Host: "localhost", Port: 5000})
+	suite.Nil(err)
+	suite.Equal("test-client", c.Name())
+
+	_, err = New(Config{Name: "unknown-client"})
+	suite.NotNil(err)
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
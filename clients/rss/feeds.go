@@ -0,0 +1,64 @@
+package rss
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Puts a feed url into the form it is stored in. Urls without a scheme are assumed to be http, only http and
+// https feeds can be read and fragments are dropped as they are never sent to the feed.
+func NormalizeFeedURL(feed string) (string, error) {
+	feed = strings.TrimSpace(feed)
+	if feed == "" {
+		return "", fmt.Errorf("feed url cannot be empty")
+	}
+	if !strings.Contains(feed, "://") {
+		// Urls like mailto:someone@example.com have a scheme, example.com:8080/rss only has a port
+		if u, err := url.Parse(feed); err == nil && u.Scheme != "" && !startsWithDigit(u.Opaque) {
+			return "", fmt.Errorf("feed url %q must be http or https", feed)
+		}
+		feed = "http://" + feed
+	}
+
+	u, err := url.Parse(feed)
+	if err != nil {
+		return "", fmt.Errorf("invalid feed url %q", feed)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("feed url %q must be http or https", feed)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("feed url %q has no host", feed)
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	normalized := u.String()
+	// Groups are stored and sent to the rss service as comma separated lists
+	if strings.Contains(normalized, ",") {
+		return "", fmt.Errorf("feed url %q cannot contain a comma", feed)
+	}
+	return normalized, nil
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// Normalizes every feed of a group, leaving out feeds that are the same as one before them
+func NormalizeFeeds(feeds []string) ([]string, error) {
+	normalized := make([]string, 0, len(feeds))
+	seen := make(map[string]bool)
+	for _, feed := range feeds {
+		n, err := NormalizeFeedURL(feed)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[n] {
+			seen[n] = true
+			normalized = append(normalized, n)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,56 @@
+package rss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FeedsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FeedsTestSuite) TestNormalizeFeedURL() {
+	for in, out := range map[string]string{
+		"http://feeds.bbci.co.uk/news/rss.xml":  "http://feeds.bbci.co.uk/news/rss.xml",
+		" https://Example.COM/Feed?format=rss ": "https://example.com/Feed?format=rss",
+		"example.com/rss":                       "http://example.com/rss",
+		"HTTPS://example.com/rss#latest":        "https://example.com/rss",
+		"localhost:8080/rss":                    "http://localhost:8080/rss",
+	} {
+		feed, err := NormalizeFeedURL(in)
+		suite.Nil(err, in)
+		suite.Equal(out, feed)
+	}
+
+	for _, feed := range []string{
+		"",
+		"   ",
+		"ftp://example.com/rss",
+		"javascript://alert(1)",
+		"mailto:someone@example.com",
+		"http://",
+		"http://example.com/a,b",
+		"http://exa mple.com/rss",
+	} {
+		_, err := NormalizeFeedURL(feed)
+		suite.NotNil(err, feed)
+	}
+}
+
+func (suite *FeedsTestSuite) TestNormalizeFeeds() {
+	feeds, err := NormalizeFeeds([]string{"example.com/rss", "http://other.com/rss", "HTTP://EXAMPLE.com/rss#top"})
+	suite.Nil(err)
+	suite.Equal([]string{"http://example.com/rss", "http://other.com/rss"}, feeds)
+
+	feeds, err = NormalizeFeeds(nil)
+	suite.Nil(err)
+	suite.Equal([]string{}, feeds)
+
+	_, err = NormalizeFeeds([]string{"http://example.com/rss", "ftp://example.com/rss"})
+	suite.NotNil(err)
+}
+
+func TestFeedsTestSuite(t *testing.T) {
+	suite.Run(t, new(FeedsTestSuite))
+}
@@ -0,0 +1,98 @@
+package rss
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The group feeds that are not in any category of an OPML document are imported into
+const UncategorizedGroup = "uncategorized"
+
+// The parts of an OPML document used to move feed lists between readers, see http://opml.org/spec2.opml
+type opml struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Title   string    `xml:"head>title"`
+	Body    []outline `xml:"body>outline"`
+}
+
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+func (o outline) name() string {
+	if o.Text != "" {
+		return strings.TrimSpace(o.Text)
+	}
+	return strings.TrimSpace(o.Title)
+}
+
+// Adds the feeds of o and every outline within it to group
+func (o outline) collect(groups map[string][]string, group string) {
+	if o.XMLURL != "" {
+		groups[group] = append(groups[group], o.XMLURL)
+	}
+	for _, child := range o.Outlines {
+		child.collect(groups, group)
+	}
+}
+
+// Reads the feeds of an OPML document as rss groups. Each top level outline that is not a feed is a category
+// whose feeds, including those of any categories within it, become a group. Feeds outside of any
+// category are put in UncategorizedGroup. Feed urls are not validated.
+func ParseOPML(r io.Reader) (map[string][]string, error) {
+	var doc opml
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, errors.New("Unable to parse OPML: " + err.Error())
+	}
+
+	groups := make(map[string][]string)
+	for _, o := range doc.Body {
+		if o.XMLURL != "" && len(o.Outlines) == 0 {
+			o.collect(groups, UncategorizedGroup)
+			continue
+		}
+
+		name := o.name()
+		if name == "" {
+			name = UncategorizedGroup
+		}
+		if _, ok := groups[name]; !ok {
+			groups[name] = []string{}
+		}
+		o.collect(groups, name)
+	}
+	return groups, nil
+}
+
+// Writes rss groups as an OPML document with a category for each group, in order of name
+func WriteOPML(w io.Writer, title string, groups map[string][]string) error {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	doc := opml{Version: "2.0", Title: title, Body: []outline{}}
+	for _, name := range names {
+		category := outline{Text: name, Title: name}
+		for _, feed := range groups[name] {
+			category.Outlines = append(category.Outlines, outline{Text: feed, Type: "rss", XMLURL: feed})
+		}
+		doc.Body = append(doc.Body, category)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return e.Encode(doc)
+}
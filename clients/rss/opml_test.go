@@ -0,0 +1,68 @@
+package rss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OPMLTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OPMLTestSuite) TestParseOPML() {
+	groups, err := ParseOPML(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="News" title="News">
+      <outline type="rss" text="BBC" xmlUrl="http://feeds.bbci.co.uk/news/rss.xml" htmlUrl="http://www.bbc.co.uk/news"/>
+      <outline text="Canada">
+        <outline type="rss" text="CBC" xmlUrl="http://www.cbc.ca/cmlink/rss-topstories"/>
+      </outline>
+    </outline>
+    <outline title="Sports">
+      <outline type="rss" text="ESPN" xmlUrl="http://www.espn.com/espn/rss/news"/>
+    </outline>
+    <outline text="Empty"><outline text="Not a feed"/></outline>
+    <outline type="rss" text="Go blog" xmlUrl="https://blog.golang.org/feed.atom"/>
+  </body>
+</opml>`))
+	suite.Nil(err)
+	suite.Equal(map[string][]string{
+		"News":             []string{"http://feeds.bbci.co.uk/news/rss.xml", "http://www.cbc.ca/cmlink/rss-topstories"},
+		"Sports":           []string{"http://www.espn.com/espn/rss/news"},
+		"Empty":            []string{},
+		UncategorizedGroup: []string{"https://blog.golang.org/feed.atom"},
+	}, groups)
+
+	_, err = ParseOPML(strings.NewReader(`<opml><body><outline`))
+	suite.NotNil(err)
+	_, err = ParseOPML(strings.NewReader(`<rss version="2.0"></rss>`))
+	suite.NotNil(err)
+}
+
+func (suite *OPMLTestSuite) TestWriteOPML() {
+	groups := map[string][]string{
+		"sports": []string{"http://www.espn.com/espn/rss/news"},
+		"news":   []string{"http://feeds.bbci.co.uk/news/rss.xml", "http://example.com/rss?a=1&b=2"},
+		"empty":  []string{},
+	}
+
+	var b bytes.Buffer
+	suite.Nil(WriteOPML(&b, "Feeds of jgore", groups))
+	suite.True(strings.HasPrefix(b.String(), `<?xml version="1.0" encoding="UTF-8"?>`))
+	suite.Contains(b.String(), `<title>Feeds of jgore</title>`)
+	suite.True(strings.Index(b.String(), `text="empty"`) < strings.Index(b.String(), `text="news"`))
+
+	// Documents that are written can be read back
+	parsed, err := ParseOPML(&b)
+	suite.Nil(err)
+	suite.Equal(groups, parsed)
+}
+
+func TestOPMLTestSuite(t *testing.T) {
+	suite.Run(t, new(OPMLTestSuite))
+}
@@ -1,9 +1,9 @@
 package rss
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 
@@ -11,43 +11,33 @@ import (
 	"github.com/iced-mocha/shared/models"
 )
 
+// RSS is not a registered client as it produces a content provider for each of a users rss groups
 type RSS struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
+	client *http.Client
 }
 
-func New(host string, port int) *RSS {
-	return &RSS{Host: host, Port: port}
+func New(conf clients.Config) (*RSS, error) {
+	client, err := clients.NewHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &RSS{conf: conf, client: client}, nil
 }
 
-func (r *RSS) GetPageGenerator(feeds []string) (func() []models.Post, error) {
-	if len(feeds) == 0 {
-		return func() []models.Post {
-			return nil
-		}, nil
+// Produces a page generator for the rss group with the given name and feeds
+func (r *RSS) GetPageGenerator(group string, feeds []string) (clients.PageGenerator, error) {
+	cursor := clients.Cursor{Client: r.Name(), Group: group}
+	if len(feeds) > 0 {
+		cursor.NextURL = fmt.Sprintf("%v/v1/posts?count=20&feeds=%v", r.conf.BaseURL(), strings.Join(feeds, ","))
 	}
 
-	nextURL := fmt.Sprintf(
-		"http://%v:%v/v1/posts?count=20&feeds=%v",
-		r.Host,
-		r.Port,
-		strings.Join(feeds, ","))
-	getNextPage := func() []models.Post {
-		if nextURL == "" {
-			return []models.Post{}
-		}
-		resp := r.getPosts(nextURL)
-		if resp.Err == nil {
-			nextURL = resp.NextURL
-			return resp.Posts
-		} else {
-			nextURL = ""
-			log.Printf("error getting rss page %v\n", resp.Err)
-			return []models.Post{}
-		}
-	}
-	return getNextPage, nil
+	return r.ResumePageGenerator(cursor)
+}
+
+func (r *RSS) ResumePageGenerator(cursor clients.Cursor) (clients.PageGenerator, error) {
+	return clients.NewURLPageGenerator(cursor, r.getPosts), nil
 }
 
 func (r *RSS) Name() string {
@@ -58,10 +48,14 @@ func (r *RSS) Weight() float64 {
 	return r.weight
 }
 
-func (r *RSS) getPosts(url string) clients.PostResponse {
+func (r *RSS) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, r.client, r.conf.BaseURL())
+}
+
+func (r *RSS) getPosts(ctx context.Context, url string) clients.PostResponse {
 	rssPosts := make([]models.Post, 0)
 
-	rssResp, err := http.Get(url)
+	rssResp, err := clients.Get(ctx, r.client, url)
 	if err != nil {
 		return clients.PostResponse{rssPosts, "", fmt.Errorf("Unable to fetch posts from rss: %v", err)}
 	}
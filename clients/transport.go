@@ -0,0 +1,128 @@
+package clients
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/iced-mocha/core/logging"
+)
+
+const (
+	// How many times a failed request is retried
+	defaultRetries = 2
+	// The delay before the first retry, doubled for every retry after that
+	defaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 2 * time.Second
+)
+
+// Transport retries requests that fail with a transient error and stops making requests to a client
+// while its breaker is open. A request counts as a single failure for the breaker once its retries
+// are exhausted.
+type Transport struct {
+	Base    http.RoundTripper
+	Breaker *Breaker
+	Retries int
+	Backoff time.Duration
+}
+
+// Wraps base, or the default transport when nil, with retries and the breaker of the named client
+func NewTransport(name string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base, Breaker: breakerFor(name), Retries: defaultRetries, Backoff: defaultBackoff}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	// Pass on the id of the request we are serving so clients can log it too
+	if id := logging.RequestID(req.Context()); id != "" && req.Header.Get(logging.RequestIDHeader) == "" {
+		req = withHeader(req, logging.RequestIDHeader, id)
+	}
+
+	resp, err := t.roundTrip(req)
+	if req.Context().Err() != nil {
+		// Requests we gave up on say nothing about the health of the client
+		t.Breaker.release()
+	} else if err != nil || retryable(resp) {
+		t.Breaker.Failure()
+	} else {
+		t.Breaker.Success()
+	}
+	return resp, err
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(req)
+		if (err == nil && !retryable(resp)) || attempt >= t.Retries || !replayable(req) {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Discard the failed response so its connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(t.delay(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Produces a copy of req with the given header set, RoundTrippers must not modify the requests they are given
+func withHeader(req *http.Request, key, value string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	r.Header.Set(key, value)
+	return r
+}
+
+// Exponential backoff with jitter so that instances of core do not retry in lockstep
+func (t *Transport) delay(attempt int) time.Duration {
+	if t.Backoff <= 0 {
+		return 0
+	}
+
+	d := t.Backoff << uint(attempt)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Whether a response indicates a failure that may go away on its own
+func retryable(resp *http.Response) bool {
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Whether the request can safely be sent again, requests with a body can only be sent again if it can be recreated
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
@@ -0,0 +1,178 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+// Creates a transport without delays between retries whose breaker uses the suites clock
+func (suite *TransportTestSuite) newTransport(retries int) *Transport {
+	b := NewBreaker("test-client", 2, time.Minute)
+	b.now = func() time.Time { return suite.now }
+	return &Transport{Base: http.DefaultTransport, Breaker: b, Retries: retries}
+}
+
+// Starts a server that responds with the given status codes in order, repeating the last one
+func (suite *TransportTestSuite) newServer(codes ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1)) - 1
+		if n >= len(codes) {
+			n = len(codes) - 1
+		}
+		w.WriteHeader(codes[n])
+	}))
+	return server, &requests
+}
+
+func (suite *TransportTestSuite) get(t *Transport, url string) (*http.Response, error) {
+	return Get(context.Background(), &http.Client{Transport: t}, url)
+}
+
+func (suite *TransportTestSuite) TestRetries() {
+	server, requests := suite.newServer(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	defer server.Close()
+
+	resp, err := suite.get(suite.newTransport(2), server.URL)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(int32(3), atomic.LoadInt32(requests))
+}
+
+func (suite *TransportTestSuite) TestNoRetryOnClientError() {
+	server, requests := suite.newServer(http.StatusNotFound)
+	defer server.Close()
+
+	t := suite.newTransport(2)
+	resp, err := suite.get(t, server.URL)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	suite.Equal(int32(1), atomic.LoadInt32(requests))
+	suite.Equal(BreakerClosed, t.Breaker.Status().State)
+}
+
+func (suite *TransportTestSuite) TestBreaker() {
+	server, requests := suite.newServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	t := suite.newTransport(0)
+	suite.now = time.Now()
+
+	// Failures up to the threshold open the breaker
+	for i := 0; i < 2; i++ {
+		resp, err := suite.get(t, server.URL)
+		suite.Nil(err)
+		suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	}
+	suite.Equal(BreakerOpen, t.Breaker.Status().State)
+	suite.Equal(2, t.Breaker.Status().Failures)
+
+	// While open requests are not sent
+	_, err := suite.get(t, server.URL)
+	suite.NotNil(err)
+	suite.Equal(int32(2), atomic.LoadInt32(requests))
+
+	// After the cooldown a failed request opens the breaker again
+	suite.now = suite.now.Add(2 * time.Minute)
+	suite.get(t, server.URL)
+	suite.Equal(int32(3), atomic.LoadInt32(requests))
+	suite.Equal(BreakerOpen, t.Breaker.Status().State)
+
+	// And a successful one closes it
+	ok, _ := suite.newServer(http.StatusOK)
+	defer ok.Close()
+	suite.now = suite.now.Add(2 * time.Minute)
+	resp, err := suite.get(t, ok.URL)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(BreakerStatus{Client: "test-client", State: BreakerClosed}, t.Breaker.Status())
+}
+
+func (suite *TransportTestSuite) TestHalfOpenAllowsOneRequest() {
+	b := NewBreaker("test-client", 1, time.Minute)
+	b.Failure()
+	suite.Equal(ErrCircuitOpen, b.Allow())
+
+	b.now = func() time.Time { return time.Now().Add(time.Hour) }
+	suite.Nil(b.Allow())
+	suite.Equal(BreakerHalfOpen, b.Status().State)
+	suite.Equal(ErrCircuitOpen, b.Allow())
+
+	// Abandoned requests let another request through
+	b.release()
+	suite.Nil(b.Allow())
+}
+
+func (suite *TransportTestSuite) TestCancelledDuringBackoff() {
+	server, _ := suite.newServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	t := suite.newTransport(5)
+	t.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := Get(ctx, &http.Client{Transport: t}, server.URL)
+	suite.NotNil(err)
+	// Giving up is not the clients fault
+	suite.Equal(BreakerClosed, t.Breaker.Status().State)
+	suite.Equal(0, t.Breaker.Status().Failures)
+}
+
+func (suite *TransportTestSuite) TestRequestID() {
+	ids := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get(logging.RequestIDHeader)
+	}))
+	defer server.Close()
+
+	ctx := logging.WithRequestID(context.Background(), "request-1")
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	suite.Nil(err)
+	_, err = (&http.Client{Transport: suite.newTransport(0)}).Do(req.WithContext(ctx))
+	suite.Nil(err)
+	suite.Equal("request-1", <-ids)
+	// The callers request is left untouched
+	suite.Empty(req.Header.Get(logging.RequestIDHeader))
+}
+
+func (suite *TransportTestSuite) TestPingBypassesTransport() {
+	server, requests := suite.newServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	// Health checks are neither retried nor counted towards the breaker
+	t := suite.newTransport(2)
+	for i := 0; i < 3; i++ {
+		suite.NotNil(Ping(context.Background(), &http.Client{Transport: t}, server.URL))
+	}
+	suite.Equal(int32(3), atomic.LoadInt32(requests))
+	suite.Equal(BreakerStatus{Client: "test-client", State: BreakerClosed}, t.Breaker.Status())
+}
+
+func (suite *TransportTestSuite) TestNewHTTPClient() {
+	c, err := NewHTTPClient(Config{Name: "breaker-client", Host: "localhost", Port: 5000})
+	suite.Nil(err)
+	suite.IsType(&Transport{}, c.Transport)
+
+	var found bool
+	for _, s := range Breakers() {
+		found = found || s.Client == "breaker-client"
+	}
+	suite.True(found)
+}
+
+func TestTransportTestSuite(t *testing.T) {
+	suite.Run(t, new(TransportTestSuite))
+}
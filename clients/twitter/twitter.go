@@ -2,89 +2,74 @@ package twitter
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
 
 	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/logging"
 	"github.com/iced-mocha/shared/models"
 )
 
+const (
+	name          = "twitter"
+	DefaultWeight = 20.0
+)
+
 type Twitter struct {
-	Host   string
-	Port   int
+	conf   clients.Config
 	weight float64
 	client *http.Client
 }
 
-func New(host string, port int) *Twitter {
-	// Load reddit-clients certifiate so we know we can trust reddit-client
-	caCert, err := ioutil.ReadFile("/usr/local/etc/ssl/certs/twitter.crt")
+func New(conf clients.Config) (*Twitter, error) {
+	client, err := clients.NewHTTPClient(conf)
 	if err != nil {
-		log.Fatal(err)
-	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: caCertPool,
-			},
-		},
+		return nil, err
 	}
-	return &Twitter{Host: host, Port: port, client: client}
+	return &Twitter{conf: conf, client: client}, nil
 }
 
 // We currently do not support unauthenticated twitter posts
-func (t *Twitter) GetDefaultPageGenerator() (func() []models.Post, error) {
-	defaultPosts := func() []models.Post {
-		return []models.Post{}
-	}
-
-	return defaultPosts, nil
+func (t *Twitter) GetDefaultPageGenerator() (clients.PageGenerator, error) {
+	return clients.EmptyPageGenerator(t.Name()), nil
 }
 
-func (t *Twitter) GetPageGenerator(user models.User) (func() []models.Post, error) {
+func (t *Twitter) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
 	if user.TwitterUsername == "" {
-		log.Printf("Getting unauthenicated twitter page generator.")
-		//nextURL = fmt.Sprintf("https://%v:%v/v1/posts", r.Host, r.Port)
+		logging.Debugf("Getting unauthenticated twitter page generator")
 		// TODO: Currently dont support this so
-		return (func() []models.Post { return []models.Post{} }), nil
+		return clients.EmptyPageGenerator(t.Name()), nil
 	}
 
-	log.Printf("Getting twitter page generator for user: %v", user.Username)
-	nextURL := fmt.Sprintf("https://%v:%v/v1/%v/posts", t.Host, t.Port, user.Username)
-
-	getNextPage := func() []models.Post {
-		log.Printf("Attemping to get twitter page with url: %v", nextURL)
-		resp := t.getPosts(nextURL, user.TwitterAuthToken, user.TwitterSecret)
-		if resp.Err != nil {
-			nextURL = ""
-			log.Printf("Error getting twitter page %v", resp.Err)
-			return []models.Post{}
-		}
+	logging.Debugf("Getting twitter page generator for user: %v", user.Username)
+	nextURL := fmt.Sprintf("%v/v1/%v/posts", t.conf.BaseURL(), user.Username)
+	return t.ResumePageGenerator(user, clients.Cursor{Client: t.Name(), NextURL: nextURL})
+}
 
-		nextURL = resp.NextURL
-		return resp.Posts
+func (t *Twitter) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
+	getPage := func(ctx context.Context, url string) clients.PostResponse {
+		logging.FromContext(ctx).Debugf("Attempting to get twitter page with url: %v", url)
+		return t.getPosts(ctx, url, user.TwitterAuthToken, user.TwitterSecret)
 	}
 
-	return getNextPage, nil
+	return clients.NewURLPageGenerator(cursor, getPage), nil
 }
 
 func (t *Twitter) Name() string {
-	return "twitter"
+	return name
 }
 
 func (t *Twitter) Weight() float64 {
 	return t.weight
 }
 
-func (t *Twitter) getPosts(url, token, secret string) clients.PostResponse {
+func (t *Twitter) Ping(ctx context.Context) error {
+	return clients.Ping(ctx, t.client, t.conf.BaseURL())
+}
+
+func (t *Twitter) getPosts(ctx context.Context, url, token, secret string) clients.PostResponse {
 	posts := []models.Post{}
 
 	authData := []byte(fmt.Sprintf(`{ "token": "%v", "secret": "%v"}`, token, secret))
@@ -93,14 +78,15 @@ func (t *Twitter) getPosts(url, token, secret string) clients.PostResponse {
 		return clients.PostResponse{posts, "", err}
 	}
 
-	log.Printf("About to get twitter posts for URL %v", url)
-	resp, err := t.client.Do(req)
+	logger := logging.FromContext(ctx)
+	logger.Debugf("About to get twitter posts for URL %v", url)
+	resp, err := t.client.Do(req.WithContext(ctx))
 	if err != nil {
 		return clients.PostResponse{posts, "", fmt.Errorf("Unable to get posts from twitter: %v", err)}
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Received status code %v requesting posts from %v", resp.StatusCode, url)
+	logger.Debugf("Received status code %v requesting posts from %v", resp.StatusCode, url)
 	if resp.StatusCode != http.StatusOK {
 		return clients.PostResponse{posts, "", fmt.Errorf("Unable to get posts from twitter received status code: %v", resp.StatusCode)}
 	}
@@ -112,7 +98,15 @@ func (t *Twitter) getPosts(url, token, secret string) clients.PostResponse {
 	}
 	posts = clientResp.Posts
 
-	log.Printf("Successfully retrieved %v posts from twitter", len(posts))
+	logger.Debugf("Successfully retrieved %v posts from twitter", len(posts))
 	// Note here I am treating the `nextURL` really as a URI
-	return clients.PostResponse{posts, fmt.Sprintf("https://%v:%v%v", t.Host, t.Port, clientResp.NextURL), nil}
+	nextURL := ""
+	if clientResp.NextURL != "" {
+		nextURL = t.conf.BaseURL() + clientResp.NextURL
+	}
+	return clients.PostResponse{posts, nextURL, nil}
+}
+
+func init() {
+	clients.Register(name, func(conf clients.Config) (clients.Client, error) { return New(conf) }, DefaultWeight)
 }
@@ -5,4 +5,7 @@ type Config interface {
 	GetString(key string) (string, error)
 	GetInts(keys []string) ([]int, error)
 	GetInt(key string) (int, error)
+	GetBool(key string) (bool, error)
+	// Produces the keys nested directly under key
+	GetKeys(key string) ([]string, error)
 }
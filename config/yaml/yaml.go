@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -175,3 +176,28 @@ func (y *Yaml) GetBool(key string) (bool, error) {
 
 	return b, nil
 }
+
+// Produces the keys of the map at key in sorted order
+func (y *Yaml) GetKeys(key string) ([]string, error) {
+	val, err := y.unwrap(key, y.data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unwrap nested value: %v\n", err)
+	}
+
+	m, ok := val.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected map value for key %v but found %T", key, val)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		s, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("Expected string keys for key %v but found %T", key, k)
+		}
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
@@ -257,6 +257,37 @@ func (suite *YamlTestSuite) TestGetString() {
 	suite.Equal("", s)
 }
 
+func (suite *YamlTestSuite) TestGetKeys() {
+	// Create tmp yaml object
+	fname := suite.writeToTempFile(testYaml)
+	config, err := New(fname)
+	suite.Nil(err)
+	suite.NotNil(config)
+	defer suite.removeTempFile(fname) // clean up
+
+	// Should be able to get the keys of a top level map
+	keys, err := config.GetKeys("a")
+	suite.Nil(err)
+	suite.Equal([]string{"host", "port"}, keys)
+
+	// Should be able to get the keys of a nested map
+	keys, err = config.GetKeys("x.b.c")
+	suite.Nil(err)
+	suite.Equal([]string{"d", "e"}, keys)
+
+	// Should fail if key does not exist
+	_, err = config.GetKeys("a.keydoesntexist")
+	suite.NotNil(err)
+
+	// Should fail if a middle part of path doesnt exist
+	_, err = config.GetKeys("x.fake.c")
+	suite.NotNil(err)
+
+	// Should fail if we try to get the keys of something that is not a map
+	_, err = config.GetKeys("y")
+	suite.NotNil(err)
+}
+
 func (suite *YamlTestSuite) TestNew() {
 	// Trying to read a yaml file that doesnt exist should fail
 	y, err := New("/var/logs/test/this/never/will/exist/at/least/i/hope/not")
@@ -10,12 +10,32 @@ type CoreAPI interface {
 	InsertUser(w http.ResponseWriter, r *http.Request)
 	GetUser(w http.ResponseWriter, r *http.Request)
 	UpdateRssFeeds(w http.ResponseWriter, r *http.Request)
+	ImportRssFeeds(w http.ResponseWriter, r *http.Request)
+	ExportRssFeeds(w http.ResponseWriter, r *http.Request)
+	GetRssGroup(w http.ResponseWriter, r *http.Request)
+	PutRssGroup(w http.ResponseWriter, r *http.Request)
+	PatchRssGroup(w http.ResponseWriter, r *http.Request)
+	DeleteRssGroup(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
 	Logout(w http.ResponseWriter, r *http.Request)
 	IsLoggedIn(w http.ResponseWriter, r *http.Request)
 	TwitterAuth(w http.ResponseWriter, r *http.Request)
 	RedditAuth(w http.ResponseWriter, r *http.Request)
 	UpdateWeights(w http.ResponseWriter, r *http.Request)
+	UpdateRankingStrategy(w http.ResponseWriter, r *http.Request)
+	MarkSeen(w http.ResponseWriter, r *http.Request)
+	SavePost(w http.ResponseWriter, r *http.Request)
+	DeleteSavedPost(w http.ResponseWriter, r *http.Request)
+	GetSavedPosts(w http.ResponseWriter, r *http.Request)
+	GetMuteFilters(w http.ResponseWriter, r *http.Request)
+	InsertMuteFilter(w http.ResponseWriter, r *http.Request)
+	DeleteMuteFilter(w http.ResponseWriter, r *http.Request)
+	GetBoosts(w http.ResponseWriter, r *http.Request)
+	InsertBoost(w http.ResponseWriter, r *http.Request)
+	DeleteBoost(w http.ResponseWriter, r *http.Request)
 	UpdateAccountAuth(w http.ResponseWriter, r *http.Request)
 	DeleteLinkedAccount(w http.ResponseWriter, r *http.Request)
+	GetClientHealth(w http.ResponseWriter, r *http.Request)
+	Healthz(w http.ResponseWriter, r *http.Request)
+	Readyz(w http.ResponseWriter, r *http.Request)
 }
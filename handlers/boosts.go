@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/ranking"
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
+	"github.com/satori/go.uuid"
+)
+
+// The most boosts a single user can have
+const maxBoosts = 200
+
+// Structure returned by us after receiving a call to GET /v1/users/{userID}/boosts
+type BoostsResponse struct {
+	Boosts []storage.Boost `json:"boosts"`
+}
+
+// Produces a booster raising the rank of the posts matching the user's boosts, nil when they have none
+func (handler *CoreHandler) postBooster(ctx context.Context, user *models.User) ranking.Booster {
+	if user == nil {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx)
+	boosts, err := handler.Driver.GetBoosts(user.Username)
+	if err != nil {
+		logger.Warnf("Unable to get boosts for user %v: %v", user.Username, err)
+		return nil
+	}
+
+	booster, err := ranking.NewBooster(boostRules(boosts))
+	if err != nil {
+		// Boosts are validated when added so this only happens if they were stored some other way
+		logger.Errorf("Unable to apply boosts for user %v: %v", user.Username, err)
+		return nil
+	}
+	return booster
+}
+
+// Converts stored boosts into the rules posts are boosted with
+func boostRules(boosts []storage.Boost) []ranking.BoostRule {
+	rules := make([]ranking.BoostRule, 0, len(boosts))
+	for _, b := range boosts {
+		rules = append(rules, ranking.BoostRule{Type: b.Type, Value: b.Value, Multiplier: b.Multiplier})
+	}
+	return rules
+}
+
+// GET /v1/users/{userID}/boosts
+// Produces every boost of the user
+func (h *CoreHandler) GetBoosts(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	boosts, err := h.Driver.GetBoosts(u.Username)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to get boosts for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(BoostsResponse{boosts})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+/* POST /v1/users/{userID}/boosts
+ * Expected body:
+ * 	{ "type": "keyword", "value": "golang", "multiplier": 2 }
+ * type is either keyword or domain. The rank of matching posts is multiplied by multiplier, which must be
+ * between 0.1 and 10. Responds with the boost as stored, including the id used to delete it.
+ */
+func (h *CoreHandler) InsertBoost(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var boost storage.Boost
+	if err := json.Unmarshal(contents, &boost); err != nil {
+		logger.Warnf("Unable to marshal request body into boost object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rule, err := ranking.NormalizeBoost(ranking.BoostRule{Type: boost.Type, Value: boost.Value, Multiplier: boost.Multiplier})
+	if err != nil {
+		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	boost.Type, boost.Value = rule.Type, rule.Value
+
+	existing, err := h.Driver.GetBoosts(u.Username)
+	if err != nil {
+		logger.Errorf("Unable to get boosts for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxBoosts {
+		http.Error(w, buildJSONError(fmt.Sprintf("Users can have at most %v boosts", maxBoosts)), http.StatusBadRequest)
+		return
+	}
+
+	boost.ID = uuid.NewV4().String()
+	if err := h.Driver.InsertBoost(u.Username, boost); err != nil {
+		logger.Errorf("Unable to insert boost for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(boost)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// DELETE /v1/users/{userID}/boosts/{boostID}
+// Removes a boost of the user
+func (h *CoreHandler) DeleteBoost(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.Driver.DeleteBoost(u.Username, mux.Vars(r)["boostID"])
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to delete boost for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
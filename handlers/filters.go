@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/ranking"
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
+	"github.com/satori/go.uuid"
+)
+
+// The most mute filters a single user can have
+const maxMuteFilters = 200
+
+// Structure returned by us after receiving a call to GET /v1/users/{userID}/filters
+type MuteFiltersResponse struct {
+	Filters []storage.MuteFilter `json:"filters"`
+}
+
+// Produces a filter leaving out the posts the user has seen or muted, nil when there is nothing to leave out
+func (handler *CoreHandler) postFilter(ctx context.Context, user *models.User) ranking.Filter {
+	if user == nil {
+		return nil
+	}
+	return ranking.AnyFilter(handler.seenFilter(ctx, user), handler.muteFilter(ctx, *user))
+}
+
+// Produces a filter leaving out the posts matching the user's mute filters, nil when they have none
+func (handler *CoreHandler) muteFilter(ctx context.Context, user models.User) ranking.Filter {
+	logger := logging.FromContext(ctx)
+	filters, err := handler.Driver.GetMuteFilters(user.Username)
+	if err != nil {
+		logger.Warnf("Unable to get mute filters for user %v: %v", user.Username, err)
+		return nil
+	}
+
+	filter, err := ranking.NewMuteFilter(muteRules(filters))
+	if err != nil {
+		// Filters are validated when added so this only happens if they were stored some other way
+		logger.Errorf("Unable to apply mute filters for user %v: %v", user.Username, err)
+		return nil
+	}
+	return filter
+}
+
+// Converts stored mute filters into the rules posts are filtered with
+func muteRules(filters []storage.MuteFilter) []ranking.MuteRule {
+	rules := make([]ranking.MuteRule, 0, len(filters))
+	for _, f := range filters {
+		rules = append(rules, ranking.MuteRule{Type: f.Type, Value: f.Value})
+	}
+	return rules
+}
+
+// GET /v1/users/{userID}/filters
+// Produces every mute filter of the user
+func (h *CoreHandler) GetMuteFilters(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := h.Driver.GetMuteFilters(u.Username)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to get mute filters for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(MuteFiltersResponse{filters})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+/* POST /v1/users/{userID}/filters
+ * Expected body:
+ * 	{ "type": "keyword", "value": "crypto" }
+ * type is one of keyword, regex, domain, subreddit or author. Responds with the filter as stored,
+ * including the id used to delete it.
+ */
+func (h *CoreHandler) InsertMuteFilter(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var filter storage.MuteFilter
+	if err := json.Unmarshal(contents, &filter); err != nil {
+		logger.Warnf("Unable to marshal request body into filter object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rule, err := ranking.NormalizeMuteRule(ranking.MuteRule{Type: filter.Type, Value: filter.Value})
+	if err != nil {
+		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	filter.Type, filter.Value = rule.Type, rule.Value
+
+	existing, err := h.Driver.GetMuteFilters(u.Username)
+	if err != nil {
+		logger.Errorf("Unable to get mute filters for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxMuteFilters {
+		http.Error(w, buildJSONError(fmt.Sprintf("Users can have at most %v mute filters", maxMuteFilters)), http.StatusBadRequest)
+		return
+	}
+
+	filter.ID = uuid.NewV4().String()
+	if err := h.Driver.InsertMuteFilter(u.Username, filter); err != nil {
+		logger.Errorf("Unable to insert mute filter for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// DELETE /v1/users/{userID}/filters/{filterID}
+// Removes a mute filter of the user
+func (h *CoreHandler) DeleteMuteFilter(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.Driver.DeleteMuteFilter(u.Username, mux.Vars(r)["filterID"])
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to delete mute filter for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,24 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/iced-mocha/core/clients"
-	"github.com/iced-mocha/core/clients/facebook"
-	"github.com/iced-mocha/core/clients/googlenews"
-	"github.com/iced-mocha/core/clients/hackernews"
-	"github.com/iced-mocha/core/clients/reddit"
 	"github.com/iced-mocha/core/clients/rss"
-	"github.com/iced-mocha/core/clients/twitter"
 	"github.com/iced-mocha/core/config"
 	"github.com/iced-mocha/core/creds"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/metrics"
+	"github.com/iced-mocha/core/paging"
 	"github.com/iced-mocha/core/ranking"
 	"github.com/iced-mocha/core/sessions"
 	"github.com/iced-mocha/core/storage"
@@ -28,15 +28,21 @@ import (
 )
 
 const (
-	DefaultRedditWeight     = 20.0
-	DefaultTwitterWeight    = 20.0
-	DefaultFacebookWeight   = 10.0
-	DefaultHackerNewsWeight = 20.0
-	DefaultGoogleNewsWeight = 20.0
-
 	// Number of posts to get in a single call to /v1/posts
 	pageSize = 40
 
+	// How long a page token can be used to get the next page of posts
+	pageTokenLifetime = 30 * time.Minute
+
+	// How long to wait for each source when building a page of posts unless configured otherwise
+	defaultProviderTimeout = 5 * time.Second
+
+	// How long posts marked as seen are left out of feeds unless configured otherwise
+	defaultSeenRetention = 30 * 24 * time.Hour
+
+	// The most posts that can be marked as seen in a single request
+	maxSeenPerRequest = 500
+
 	// This is the default message used for sending back to client. I.e this will be show in dialogs in front-end
 	InternalErrorMsg = "Unable to complete request. Please try again later."
 )
@@ -61,19 +67,74 @@ type CoreHandler struct {
 	Driver         storage.Driver
 	Config         config.Config
 	SessionManager sessions.IManager
-	// TODO: Having a cache on core used for pagination requires us to only run
-	// one instance of core
-	Cache              *cache.Cache
-	getNextPagingToken func() string
+	// Page tokens are self contained so any instance of core can serve the next page. This cache
+	// just lets the instance that served the previous page reuse its content providers.
+	Cache  *cache.Cache
+	Signer *paging.Signer
 
 	Clients   []clients.Client
 	RssClient *rss.RSS
+	// How long to wait for each source when building a page of posts
+	ProviderTimeout time.Duration
+	// How long posts marked as seen are left out of feeds
+	SeenRetention time.Duration
+	// How often MonitorHealth probes the dependencies of core
+	HealthInterval time.Duration
+	health         *healthCache
 }
 
 // Structure returned by us after receiving a call to /v1/posts
 type PostsResponse struct {
-	Posts     []models.Post `json:"posts"`
-	PageToken string        `json:"page_token"`
+	Posts     []ranking.Post `json:"posts"`
+	PageToken string         `json:"page_token"`
+	// Sources that did not respond in time, their posts are missing from this page
+	DegradedSources []string `json:"degraded_sources"`
+}
+
+// The publicly visible parts of a user returned by our api. This must never contain the
+// password hash or the tokens of linked accounts.
+type UserView struct {
+	ID                string              `json:"id"`
+	Username          string              `json:"username"`
+	RedditUsername    string              `json:"reddit-username"`
+	RedditConnected   bool                `json:"reddit-connected"`
+	TwitterUsername   string              `json:"twitter-username"`
+	TwitterConnected  bool                `json:"twitter-connected"`
+	FacebookUsername  string              `json:"facebook-username"`
+	FacebookConnected bool                `json:"facebook-connected"`
+	PostWeights       models.Weights      `json:"weights"`
+	RssGroups         map[string][]string `json:"rss-groups"`
+	RankingStrategy   string              `json:"ranking-strategy"`
+}
+
+func NewUserView(user models.User, strategy string) UserView {
+	if strategy == "" {
+		strategy = ranking.DefaultStrategy
+	}
+
+	return UserView{
+		ID:                user.ID,
+		Username:          user.Username,
+		RedditUsername:    user.RedditUsername,
+		RedditConnected:   user.RedditAuthToken != "",
+		TwitterUsername:   user.TwitterUsername,
+		TwitterConnected:  user.TwitterAuthToken != "",
+		FacebookUsername:  user.FacebookUsername,
+		FacebookConnected: user.FacebookAuthToken != "",
+		PostWeights:       user.PostWeights,
+		RssGroups:         user.RssGroups,
+		RankingStrategy:   strategy,
+	}
+}
+
+// Structure received when a user marks posts as seen
+type SeenRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// Structure received when a user picks a ranking strategy
+type StrategyRequest struct {
+	Strategy string `json:"strategy"`
 }
 
 // Structure received from one of our clients when updating their auth info
@@ -85,42 +146,56 @@ type ProviderAuth struct {
 	Secret       string `json:"secret"`
 }
 
-func New(d storage.Driver, sm *sessions.Manager, conf config.Config, c *cache.Cache) (*CoreHandler, error) {
+func New(d storage.Driver, sm *sessions.Manager, conf config.Config, c *cache.Cache, signer *paging.Signer) (*CoreHandler, error) {
 	handler := &CoreHandler{}
 	handler.Driver = d
 	handler.Config = conf
 	handler.SessionManager = sm
 	handler.Cache = c
+	handler.Signer = signer
 
-	var idCounter int32
-	handler.getNextPagingToken = func() string {
-		idCounter++
-		return strconv.FormatInt(int64(idCounter), 32)
+	handler.ProviderTimeout = defaultProviderTimeout
+	if ms, err := conf.GetInt("posts.provider-timeout-ms"); err == nil && ms > 0 {
+		handler.ProviderTimeout = time.Duration(ms) * time.Millisecond
 	}
 
-	// Start our session garbage collection
-	//go handler.SessionManager.GC()
-
-	// TODO Find a better way to do this
-	// Maybe create a GetStringKeys function that returns array of values and a potential error
+	handler.HealthInterval = defaultHealthInterval
+	if ms, err := conf.GetInt("health.probe-interval-ms"); err == nil && ms > 0 {
+		handler.HealthInterval = time.Duration(ms) * time.Millisecond
+	}
+	handler.health = &healthCache{}
 
-	hosts, err := handler.Config.GetStrings([]string{"hacker-news.host", "facebook.host", "reddit.host", "google-news.host", "twitter.host", "rss.host"})
-	if err != nil {
-		return nil, err
+	handler.SeenRetention = defaultSeenRetention
+	if hours, err := conf.GetInt("posts.seen-retention-hours"); err == nil && hours > 0 {
+		handler.SeenRetention = time.Duration(hours) * time.Hour
 	}
 
-	ports, err := handler.Config.GetInts([]string{"hacker-news.port", "facebook.port", "reddit.port", "google-news.port", "twitter.port", "rss.port"})
+	// Create every registered client that is enabled in our configuration
+	configs, err := clients.ReadConfigs(handler.Config)
 	if err != nil {
 		return nil, err
 	}
 
-	handler.Clients = make([]clients.Client, 5)
-	handler.Clients[0] = hackernews.New(hosts[0], ports[0])
-	handler.Clients[1] = facebook.New(hosts[1], ports[1])
-	handler.Clients[2] = reddit.New(hosts[2], ports[2])
-	handler.Clients[3] = googlenews.New(hosts[3], ports[3])
-	handler.Clients[4] = twitter.New(hosts[4], ports[4])
-	handler.RssClient = rss.New(hosts[5], ports[5])
+	for _, c := range configs {
+		if !c.Enabled {
+			logging.Infof("Client %v is disabled", c.Name)
+			continue
+		}
+
+		// rss is not a registered client as it provides content per rss group
+		if c.Name == "rss" {
+			if handler.RssClient, err = rss.New(c); err != nil {
+				return nil, fmt.Errorf("Unable to create rss client: %v", err)
+			}
+			continue
+		}
+
+		client, err := clients.New(c)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create client %v: %v", c.Name, err)
+		}
+		handler.Clients = append(handler.Clients, client)
+	}
 
 	return handler, nil
 }
@@ -131,20 +206,21 @@ func New(d storage.Driver, sm *sessions.Manager, conf config.Config, c *cache.Ca
  */
 func (h *CoreHandler) UpdateWeights(w http.ResponseWriter, r *http.Request) {
 	userID := mux.Vars(r)["userID"]
-	log.Printf("Received request to update weights for user: %v", userID)
+	logger := logging.FromContext(r.Context())
+	logger.Debugf("Received request to update weights for user: %v", userID)
 
 	hasAuth, code := h.hasAuthorization(userID, r)
 	if !hasAuth {
-		log.Printf("Unable to update weights for user: %v", userID)
+		logger.Infof("Unable to update weights for user: %v", userID)
 		w.WriteHeader(code)
 		return
 	}
-	log.Printf("Preparing to update weights for user: %v", userID)
+	logger.Debugf("Preparing to update weights for user: %v", userID)
 
 	// Now attempt to get the user for the username
 	u, exists, err := h.Driver.GetUser(userID)
 	if !exists || err != nil {
-		log.Printf("Unable to retireve user from database when trying to update weights")
+		logger.Errorf("Unable to retrieve user %v from database when trying to update weights: %v", userID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -158,22 +234,46 @@ func (h *CoreHandler) UpdateWeights(w http.ResponseWriter, r *http.Request) {
 
 	weights := &models.Weights{}
 	if err := json.Unmarshal(contents, weights); err != nil {
-		log.Printf("Unable to marshal request body into weights object: %v", err)
+		logger.Warnf("Unable to marshal request body into weights object: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	if !h.Driver.UpdateWeights(u.Username, *weights) {
 		// Insert our user with new weights into DB
+		logger.Errorf("Unable to update weights for user %v", u.Username)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	// Clients without a field in models.Weights can still be weighted by name
+	sources := make(map[string]interface{})
+	if err := json.Unmarshal(contents, &sources); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	others := make(map[string]float64)
+	for source, v := range sources {
+		if weight, ok := v.(float64); ok && !isModelWeight(source) {
+			others[source] = weight
+		}
+	}
+
+	if len(others) > 0 {
+		if err := h.Driver.UpdateSourceWeights(u.Username, others); err != nil {
+			logger.Errorf("Unable to update source weights for user %v: %v", u.Username, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *CoreHandler) UpdateRssFeeds(w http.ResponseWriter, r *http.Request) {
 	userID := mux.Vars(r)["userID"]
+	logger := logging.FromContext(r.Context())
 
 	hasAuth, code := h.hasAuthorization(userID, r)
 	if !hasAuth {
@@ -183,7 +283,7 @@ func (h *CoreHandler) UpdateRssFeeds(w http.ResponseWriter, r *http.Request) {
 
 	u, exists, err := h.Driver.GetUser(userID)
 	if !exists || err != nil {
-		log.Printf("Unable to retrieve user from database when trying to update weights")
+		logger.Errorf("Unable to retrieve user %v from database when trying to update rss feeds: %v", userID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -196,13 +296,158 @@ func (h *CoreHandler) UpdateRssFeeds(w http.ResponseWriter, r *http.Request) {
 
 	var feeds map[string][]string
 	if err := json.Unmarshal(contents, &feeds); err != nil {
-		log.Printf("Unable to marshal request body into weights object: %v", err)
+		logger.Warnf("Unable to marshal request body into rss feeds object: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	for name, group := range feeds {
+		if err := validateRssGroupName(name); err != nil {
+			http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+			return
+		}
+		if feeds[name], err = normalizeRssGroupFeeds(group); err != nil {
+			http.Error(w, buildJSONError(fmt.Sprintf("Invalid rss group %v: %v", name, err)), http.StatusBadRequest)
+			return
+		}
+	}
+
 	if err := h.Driver.UpdateRssFeeds(u.Username, feeds); err != nil {
-		log.Printf("Unable to update rss feeds: %v", err)
+		logger.Errorf("Unable to update rss feeds for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+/* POST /v1/users/{userID}/seen
+ * Expected body:
+ * 	{ "ids": ["post-id", ...] }
+ * Seen posts are left out of the user's feeds until they have been seen for longer than the retention
+ */
+func (h *CoreHandler) MarkSeen(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userID"]
+	logger := logging.FromContext(r.Context())
+
+	hasAuth, code := h.hasAuthorization(userID, r)
+	if !hasAuth {
+		w.WriteHeader(code)
+		return
+	}
+
+	u, exists, err := h.Driver.GetUser(userID)
+	if !exists || err != nil {
+		logger.Errorf("Unable to retrieve user %v from database when trying to mark posts seen: %v", userID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	req := SeenRequest{}
+	if err := json.Unmarshal(contents, &req); err != nil {
+		logger.Warnf("Unable to marshal request body into seen object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if len(req.IDs) > maxSeenPerRequest {
+		http.Error(w, buildJSONError(fmt.Sprintf("At most %v posts can be marked seen at once", maxSeenPerRequest)), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]string, 0, len(req.IDs))
+	unique := make(map[string]bool)
+	for _, id := range req.IDs {
+		if id != "" && !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	now := time.Now()
+	if err := h.Driver.MarkSeen(u.Username, ids, now); err != nil {
+		logger.Errorf("Unable to mark posts seen for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Posts are only remembered for as long as they are left out of feeds
+	if err := h.Driver.DeleteSeen(u.Username, now.Add(-h.SeenRetention)); err != nil {
+		logger.Warnf("Unable to delete expired seen posts for user %v: %v", u.Username, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Produces a filter leaving out the posts the user has seen, nil when there is nothing to leave out
+func (handler *CoreHandler) seenFilter(ctx context.Context, user *models.User) ranking.Filter {
+	if user == nil {
+		return nil
+	}
+
+	seen, err := handler.Driver.GetSeen(user.Username, time.Now().Add(-handler.SeenRetention))
+	if err != nil {
+		// Showing seen posts again is better than showing no posts
+		logging.FromContext(ctx).Warnf("Unable to get seen posts for user %v: %v", user.Username, err)
+		return nil
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	return func(p models.Post) bool {
+		return seen[p.ID]
+	}
+}
+
+/* POST /v1/users/{userID}/strategy
+ * Expected body:
+ * 	{ "strategy": "chronological" }
+ * An empty strategy goes back to the default
+ */
+func (h *CoreHandler) UpdateRankingStrategy(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userID"]
+	logger := logging.FromContext(r.Context())
+
+	hasAuth, code := h.hasAuthorization(userID, r)
+	if !hasAuth {
+		w.WriteHeader(code)
+		return
+	}
+
+	u, exists, err := h.Driver.GetUser(userID)
+	if !exists || err != nil {
+		logger.Errorf("Unable to retrieve user %v from database when trying to update ranking strategy: %v", userID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	req := StrategyRequest{}
+	if err := json.Unmarshal(contents, &req); err != nil {
+		logger.Warnf("Unable to marshal request body into strategy object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if _, err := ranking.StrategyFor(req.Strategy); err != nil {
+		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Driver.UpdateRankingStrategy(u.Username, req.Strategy); err != nil {
+		logger.Errorf("Unable to update ranking strategy for user %v: %v", u.Username, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -249,6 +494,7 @@ func (h *CoreHandler) DeleteLinkedAccount(w http.ResponseWriter, r *http.Request
 func (h *CoreHandler) UpdateAccountAuth(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, t := vars["userID"], vars["type"]
+	logger := logging.FromContext(r.Context())
 
 	hasAuth, code := h.hasAuthorization(userID, r)
 	if !hasAuth {
@@ -261,16 +507,17 @@ func (h *CoreHandler) UpdateAccountAuth(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "unable to read request body", http.StatusBadRequest)
 		return
 	}
-	log.Printf("About to update %v auth information for user: %v", t, userID)
+	logger.Debugf("About to update %v auth information for user: %v", t, userID)
 
 	auth := &ProviderAuth{}
 	if err := json.Unmarshal(body, auth); err != nil {
-		log.Printf("Error parsing request body when updating %v auth for user: %v - %v", t, userID, err)
+		logger.Warnf("Error parsing request body when updating %v auth for user: %v - %v", t, userID, err)
 		http.Error(w, "unable to parse body", http.StatusBadRequest)
 		return
 	}
 
 	if err, code := h.insertAuth(userID, t, *auth); err != nil {
+		logger.Warnf("Unable to update %v auth for user %v: %v", t, userID, err)
 		w.WriteHeader(code)
 		return
 	}
@@ -281,9 +528,7 @@ func (h *CoreHandler) UpdateAccountAuth(w http.ResponseWriter, r *http.Request)
 // Inserts the provider auth for Reddit if it has the required keys
 func (h *CoreHandler) updateRedditAuth(userID string, auth ProviderAuth) (error, int) {
 	if !validRedditAuth(auth) {
-		msg := fmt.Sprintf("Received empty field in provided auth body while updating reddit account")
-		log.Println(msg)
-		return errors.New(msg), http.StatusBadRequest
+		return errors.New("Received empty field in provided auth body while updating reddit account"), http.StatusBadRequest
 	}
 
 	successful := h.Driver.UpdateRedditAccount(userID, auth.Username, auth.Token, auth.Secret)
@@ -323,7 +568,7 @@ func (handler *CoreHandler) hasAuthorization(user string, r *http.Request) (bool
 	// First we must verify that the incoming request is allowed to modify this users data
 	s, err := handler.SessionManager.GetSession(r)
 	if err != nil {
-		log.Printf("Unable to find valid session for incoming request for user %v", user)
+		logging.FromContext(r.Context()).Infof("Unable to find valid session for incoming request for user %v", user)
 		return false, http.StatusUnauthorized
 	}
 
@@ -348,21 +593,28 @@ func validRedditAuth(auth ProviderAuth) bool {
 // Redirects to our reddit client to authorize or service to use reddit account
 // GET /v1/user/{userID}/authorize/reddit
 func (handler *CoreHandler) RedditAuth(w http.ResponseWriter, r *http.Request) {
-	userID := mux.Vars(r)["userID"]
-	// TODO rip out this config
-	host, _ := handler.Config.GetString("reddit.host")
-	port, _ := handler.Config.GetInt("reddit.port")
-	http.Redirect(w, r, "https://"+host+":"+strconv.Itoa(port)+"/v1/"+userID+"/authorize", http.StatusMovedPermanently)
+	handler.redirectToAuthorize(w, r, "reddit")
 }
 
 // Redirects to our twitter client to authorize our service to use the users twitter account
 // GET /v1/user/{userID}/authorize/twitter
 func (handler *CoreHandler) TwitterAuth(w http.ResponseWriter, r *http.Request) {
+	handler.redirectToAuthorize(w, r, "twitter")
+}
+
+// Redirects to the authorize endpoint of the named client for the user in the request
+func (handler *CoreHandler) redirectToAuthorize(w http.ResponseWriter, r *http.Request, name string) {
 	userID := mux.Vars(r)["userID"]
-	// TODO rip out this config
-	host, _ := handler.Config.GetString("twitter.host")
-	port, _ := handler.Config.GetInt("twitter.port")
-	http.Redirect(w, r, "https://"+host+":"+strconv.Itoa(port)+"/v1/"+userID+"/authorize", http.StatusMovedPermanently)
+	c, err := clients.ReadConfig(handler.Config, name)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to read configuration for %v: %v", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Users are always sent to the authorize pages over https
+	c.TLS.Enabled = true
+	http.Redirect(w, r, c.BaseURL()+"/v1/"+userID+"/authorize", http.StatusMovedPermanently)
 }
 
 // TODO: Probably isnt safe to have this endpoint as it could allow for an easy brute force attack
@@ -396,6 +648,7 @@ func buildJSONError(message string) string {
  * Note: Error messages here are user facing
  */
 func (handler *CoreHandler) Login(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, buildJSONError(InternalErrorMsg), http.StatusBadRequest)
@@ -414,7 +667,7 @@ func (handler *CoreHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Received the following user to login: %v", attemptedUser.Username)
+	logger.Debugf("Received the following user to login: %v", attemptedUser.Username)
 
 	// First check to see if the user is already logged in
 	if handler.SessionManager.HasSession(r) {
@@ -426,14 +679,14 @@ func (handler *CoreHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Get the actual user for the given username
 	actualUser, exists, err := handler.Driver.GetUser(attemptedUser.Username)
 	if err != nil {
-		log.Printf("Unable to retrieve user %v from db: %v", attemptedUser.Username, err)
+		logger.Errorf("Unable to retrieve user %v from db: %v", attemptedUser.Username, err)
 		http.Error(w, buildJSONError(InternalErrorMsg), http.StatusInternalServerError)
 		return
 	}
 
 	// If the user does not exist return 401 (Unauthorized) for security reasons
 	if !exists {
-		log.Printf("Requested user %v does not exist", attemptedUser.Username)
+		logger.Infof("Requested user %v does not exist", attemptedUser.Username)
 		http.Error(w, buildJSONError("Incorrect username or password"), http.StatusUnauthorized)
 		return
 	}
@@ -443,7 +696,7 @@ func (handler *CoreHandler) Login(w http.ResponseWriter, r *http.Request) {
 	valid := creds.CheckPasswordHash(attemptedUser.Password, actualUser.Password)
 	if !valid {
 		// Not valid so return unauthorized
-		log.Printf("Bad credentials attempting to authenticate user %v", attemptedUser.Username)
+		logger.Infof("Bad credentials attempting to authenticate user %v", attemptedUser.Username)
 		http.Error(w, buildJSONError("Incorrect username or password"), http.StatusUnauthorized)
 		return
 	}
@@ -481,7 +734,13 @@ func (handler *CoreHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contents, err := json.Marshal(user)
+	strategy, err := handler.Driver.GetRankingStrategy(user.Username)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contents, err := json.Marshal(NewUserView(user, strategy))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -496,32 +755,34 @@ func (handler *CoreHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 func (handler *CoreHandler) InsertUser(w http.ResponseWriter, r *http.Request) {
 	// TODO: dont think the below line is needed any more
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	logger := logging.FromContext(r.Context())
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading body: %v", err)
+		logger.Warnf("Error reading body: %v", err)
 		http.Error(w, "can't read body", http.StatusBadRequest)
 		return
 	}
-	log.Printf("Received the following user to signup: %v", string(body))
 
 	// Marshal the body into a user object
 	user := &models.User{}
 	if err := json.Unmarshal(body, user); err != nil {
-		log.Printf("Error parsing body: %v", err)
+		logger.Warnf("Error parsing body: %v", err)
 		http.Error(w, "can't parse body", http.StatusBadRequest)
 		return
 	}
+	// The body holds the plaintext password so only the username is logged
+	logger.Infof("Received request to sign up user %v", user.Username)
 
 	// verify username and password meet out criteria of valid
 	if err := creds.ValidateSignupCredentials(user.Username, user.Password); err != nil {
-		log.Printf("Attempted to sign up user %v with invalid credentials - %v", user.Username, err)
+		logger.Infof("Attempted to sign up user %v with invalid credentials - %v", user.Username, err)
 		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
 		return
 	}
 
 	_, exists, err := handler.Driver.GetUser(user.Username)
 	if exists || err != nil {
-		log.Printf("Attempted to sign up user %v but username already exists", user.Username)
+		logger.Infof("Attempted to sign up user %v but username already exists", user.Username)
 		http.Error(w, buildJSONError(fmt.Sprintf("Attempted to sign up with username: %v - but username already exists", user.Username)),
 			http.StatusBadRequest)
 		return
@@ -539,11 +800,11 @@ func (handler *CoreHandler) InsertUser(w http.ResponseWriter, r *http.Request) {
 
 	// Add the default weighting to the user struct
 	pw := models.Weights{}
-	pw.Reddit = DefaultRedditWeight
-	pw.Facebook = DefaultFacebookWeight
-	pw.HackerNews = DefaultHackerNewsWeight
-	pw.GoogleNews = DefaultGoogleNewsWeight
-	pw.Twitter = DefaultTwitterWeight
+	pw.Reddit = clients.DefaultWeight("reddit")
+	pw.Facebook = clients.DefaultWeight("facebook")
+	pw.HackerNews = clients.DefaultWeight("hacker-news")
+	pw.GoogleNews = clients.DefaultWeight("google-news")
+	pw.Twitter = clients.DefaultWeight("twitter")
 	pw.RSS = DefaultRssWeights
 	user.PostWeights = pw
 	user.RssGroups = DefaultRssGroups
@@ -563,40 +824,31 @@ func writePosts(w http.ResponseWriter, posts []models.Post) {
 	w.Write(res)
 }
 
-func getDefaultWeight(clientName string) float64 {
-	var val int
-
-	if clientName == "reddit" {
-		val = DefaultRedditWeight
-	} else if clientName == "facebook" {
-		val = DefaultFacebookWeight
-	} else if clientName == "hacker-news" {
-		val = DefaultHackerNewsWeight
-	} else if clientName == "google-news" {
-		val = DefaultGoogleNewsWeight
-	} else if clientName == "twitter" {
-		val = DefaultTwitterWeight
+// Whether the source has its own field in models.Weights
+func isModelWeight(source string) bool {
+	switch source {
+	case "reddit", "facebook", "hacker-news", "google-news", "twitter", "rss":
+		return true
 	}
-
-	return float64(val)
+	return false
 }
 
-func getWeight(clientName string, user models.User) float64 {
-	var val float64
-
-	if clientName == "reddit" {
-		val = user.PostWeights.Reddit
-	} else if clientName == "facebook" {
-		val = user.PostWeights.Facebook
-	} else if clientName == "hacker-news" {
-		val = user.PostWeights.HackerNews
-	} else if clientName == "google-news" {
-		val = user.PostWeights.GoogleNews
-	} else if clientName == "twitter" {
-		val = user.PostWeights.Twitter
+// Gets the weight the user gave the client, falling back to the clients default weight
+func getWeight(clientName string, weights map[string]float64) float64 {
+	if weight, ok := weights[clientName]; ok {
+		return weight
 	}
+	return clients.DefaultWeight(clientName)
+}
 
-	return val
+// Gets the weights of every source for the user, on error the default weights are used
+func (handler *CoreHandler) getUserWeights(ctx context.Context, user models.User) map[string]float64 {
+	weights, err := handler.Driver.GetWeights(user.Username)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to get weights for user %v using defaults: %v", user.Username, err)
+		return map[string]float64{}
+	}
+	return weights
 }
 
 func (handler *CoreHandler) getClient(t string) (clients.Client, error) {
@@ -612,110 +864,205 @@ func (handler *CoreHandler) getClient(t string) (clients.Client, error) {
 // Produces a list of content providers for each of our supported clients.
 // A content provider structure stores information about the current page of data being
 // read from that content provider, and a function to get the next page of data.
-func (handler *CoreHandler) getProvidersForUser(r *http.Request, user models.User) []*ranking.ContentProvider {
+func (handler *CoreHandler) getProvidersForUser(ctx context.Context, r *http.Request, user models.User) providerSet {
 	clientList := handler.Clients
 	if v, ok := mux.Vars(r)["type"]; ok {
 		// If a specific type was specified in the request we must modify the client list
 		c, err := handler.getClient(v)
 		if err != nil {
-			return []*ranking.ContentProvider{}
+			return providerSet{providers: []*ranking.ContentProvider{}, degraded: []string{}}
 		}
 		clientList = []clients.Client{c}
 	}
 
-	var numProviders int
+	weights := handler.getUserWeights(ctx, user)
 
-	// Construct a buffered channel to hold results from each of our client
-	ch := make(chan *ranking.ContentProvider)
+	b := newProviderBuilder(ctx, handler.postFilter(ctx, &user), handler.postBooster(ctx, &user))
 	for _, client := range clientList {
 		generator, err := client.GetPageGenerator(user)
 		if err != nil {
-			log.Printf("Unable to get page %v generator for user %v: %v", client.Name(), user.Username, err)
+			logging.FromContext(ctx).Warnf("Unable to get page %v generator for user %v: %v", client.Name(), user.Username, err)
 			continue
 		}
 
-		numProviders++
-		go func(name string, ch chan *ranking.ContentProvider) {
-			// Sometimes user is nil when we are not authenticated
-			weight := getWeight(name, user)
-			ch <- ranking.NewContentProvider(weight, generator)
-		}(client.Name(), ch)
+		b.add(client.Name(), getWeight(client.Name(), weights), generator)
 	}
 
-	// Increases the number of providers we have for each RSS feed that we trigger to get content providers
-	numProviders += handler.GetRSSProviders(ch, user.RssGroups, user.PostWeights.RSS)
+	handler.GetRSSProviders(b, user.RssGroups, user.PostWeights.RSS)
 
-	return buildProviders(ch, numProviders)
+	return b.wait()
 }
 
 // Gets the default providers for an unauthenticated user
-func (handler *CoreHandler) getDefaultProviders(r *http.Request) []*ranking.ContentProvider {
+func (handler *CoreHandler) getDefaultProviders(ctx context.Context, r *http.Request) providerSet {
 	clientList := handler.Clients
 	if v, ok := mux.Vars(r)["type"]; ok {
 		// If a specific type was specified in the request we must modify the client list
 		c, err := handler.getClient(v)
 		if err != nil {
-			return []*ranking.ContentProvider{}
+			return providerSet{providers: []*ranking.ContentProvider{}, degraded: []string{}}
 		}
 		clientList = []clients.Client{c}
 	}
 
-	var numProviders int
-
-	// Construct a buffered channel to hold results from each of our client
-	ch := make(chan *ranking.ContentProvider)
+	b := newProviderBuilder(ctx, nil, nil)
 	for _, client := range clientList {
-		// TODO implement this
 		generator, err := client.GetDefaultPageGenerator()
 		if err != nil {
-			log.Printf("Unable to get default page generator for %v: %v", client.Name(), err)
+			logging.FromContext(ctx).Warnf("Unable to get default page generator for %v: %v", client.Name(), err)
 			continue
 		}
 
-		numProviders++
-		go func(name string, ch chan *ranking.ContentProvider) {
-			// Sometimes user is nil when we are not authenticated
-			weight := getDefaultWeight(name)
-			ch <- ranking.NewContentProvider(weight, generator)
-		}(client.Name(), ch)
+		b.add(client.Name(), clients.DefaultWeight(client.Name()), generator)
 	}
 
-	// Increases the number of providers we have for each RSS feed that we trigger to get content providers
-	numProviders += handler.GetRSSProviders(ch, DefaultRssGroups, DefaultRssWeights)
+	handler.GetRSSProviders(b, DefaultRssGroups, DefaultRssWeights)
+
+	return b.wait()
+}
+
+// A content provider along with the source it reads from
+type providerResult struct {
+	source   string
+	provider *ranking.ContentProvider
+}
 
-	return buildProviders(ch, numProviders)
+// Creates content providers concurrently, collecting the ones whose first page arrives before ctx is done
+type providerBuilder struct {
+	ctx     context.Context
+	results chan providerResult
+	sources []string
+	// Where each source starts reading from, given to sources that are not ready in time
+	cursors map[string]clients.Cursor
+	// Applied to every provider, may be nil
+	filter  ranking.Filter
+	booster ranking.Booster
 }
 
-// Reads n content providers off the given channel and returns them in slice form
-func buildProviders(ch chan *ranking.ContentProvider, n int) []*ranking.ContentProvider {
-	providers := []*ranking.ContentProvider{}
-	for i := 0; i < n; i++ {
-		// TODO: Probably we should have some sort of timeout mechanism
-		providers = append(providers, <-ch)
+func newProviderBuilder(ctx context.Context, filter ranking.Filter, booster ranking.Booster) *providerBuilder {
+	return &providerBuilder{
+		ctx:     ctx,
+		results: make(chan providerResult),
+		cursors: make(map[string]clients.Cursor),
+		filter:  filter,
+		booster: booster,
 	}
+}
 
-	return providers
+// Starts creating a content provider for source
+func (b *providerBuilder) add(source string, weight float64, generator clients.PageGenerator) {
+	b.resume(source, weight, generator, nil)
 }
 
-// Consumes a map of RSS groups, their weights and channel to put the results on
-// Returns the number of content providers successfully retrieved
-func (handler *CoreHandler) GetRSSProviders(ch chan *ranking.ContentProvider, groups map[string][]string, weights map[string]float64) int {
-	var numProviders int
+// Starts recreating the content provider for source at cursor, a nil cursor creates a new provider
+func (b *providerBuilder) resume(source string, weight float64, generator clients.PageGenerator, cursor *clients.Cursor) {
+	b.sources = append(b.sources, source)
+	// The generator is only read from by the provider once it is created
+	if cursor == nil {
+		b.cursors[source] = generator.Cursor()
+	} else {
+		b.cursors[source] = *cursor
+	}
+	go func() {
+		var p *ranking.ContentProvider
+		if cursor == nil {
+			p = ranking.NewContentProvider(b.ctx, weight, generator)
+		} else {
+			p = ranking.ResumeContentProvider(b.ctx, weight, generator, *cursor)
+		}
+		if b.filter != nil {
+			p.SetFilter(b.ctx, b.filter)
+		}
+		p.SetBooster(b.booster)
+
+		// Nobody is waiting for providers that are not ready in time
+		select {
+		case b.results <- providerResult{source, p}:
+		case <-b.ctx.Done():
+		}
+	}()
+}
+
+// The content providers a page is ranked from
+type providerSet struct {
+	providers []*ranking.ContentProvider
+	// Sources that failed or did not respond in time, in sorted order
+	degraded []string
+	// Where each source that did not respond in time starts, kept in the page token so the next page retries it
+	pending []clients.Cursor
+}
+
+// Waits for every provider to be created or for ctx to be done, whichever comes first. Produces the
+// providers that are ready and the sources that failed or did not respond in time. Providers that
+// failed are still produced so that the page token retries them.
+func (b *providerBuilder) wait() providerSet {
+	set := providerSet{providers: []*ranking.ContentProvider{}, degraded: []string{}}
+	ready := make(map[string]bool)
+	logger := logging.FromContext(b.ctx)
+
+	for len(set.providers) < len(b.sources) {
+		select {
+		case r := <-b.results:
+			set.providers = append(set.providers, r.provider)
+			ready[r.source] = true
+			if r.provider.Err() != nil {
+				set.degraded = append(set.degraded, r.source)
+			}
+		case <-b.ctx.Done():
+			for _, source := range b.sources {
+				if !ready[source] {
+					logger.Warnf("Timed out waiting for content provider %v", source)
+					set.degraded = append(set.degraded, source)
+					set.pending = append(set.pending, b.cursors[source])
+				}
+			}
+			set.sort()
+			return set
+		}
+	}
+
+	set.sort()
+	return set
+}
+
+// Orders everything in the set by source. Providers are ready in whatever order their first pages arrive, but
+// strategies draw random numbers and break ties by the order of providers so a seed only reproduces a page when
+// that order is always the same.
+func (set *providerSet) sort() {
+	sort.Slice(set.providers, func(i, j int) bool {
+		return set.providers[i].Cursor().Source() < set.providers[j].Cursor().Source()
+	})
+	sortCursors(set.pending)
+	sort.Strings(set.degraded)
+}
+
+// Orders cursors by the source they read from
+func sortCursors(cursors []clients.Cursor) {
+	sort.Slice(cursors, func(i, j int) bool {
+		return cursors[i].Source() < cursors[j].Source()
+	})
+}
+
+// Produces the name of the source for the given rss group
+func rssSource(group string) string {
+	return clients.Cursor{Client: "rss", Group: group}.Source()
+}
+
+// Starts creating a content provider for each of the given rss groups
+func (handler *CoreHandler) GetRSSProviders(b *providerBuilder, groups map[string][]string, weights map[string]float64) {
+	if handler.RssClient == nil {
+		return
+	}
 
 	for name, group := range groups {
-		generator, err := handler.RssClient.GetPageGenerator(group)
+		generator, err := handler.RssClient.GetPageGenerator(name, group)
 		if err != nil {
-			log.Printf("Unable to get page generator for rss group %v: %v", name, err)
+			logging.FromContext(b.ctx).Warnf("Unable to get page generator for rss group %v: %v", name, err)
 			continue
 		}
 
-		numProviders++
-		go func(name string, ch chan *ranking.ContentProvider) {
-			ch <- ranking.NewContentProvider(weights[name], generator)
-		}(name, ch)
+		b.add(rssSource(name), weights[name], generator)
 	}
-
-	return numProviders
 }
 
 // GET /v1/posts/{type}
@@ -728,71 +1075,280 @@ func (handler *CoreHandler) GetPostsType(w http.ResponseWriter, r *http.Request)
 
 // GET /v1/posts
 // Produces the next set of posts for the incoming request specified by an optional
-// page_token query paramater
+// page_token query paramater. The optional strategy query parameter picks how posts
+// are ranked, otherwise the strategy of the page token or of the user is used.
 func (handler *CoreHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
-	// First we must determine if the incoming user is making the request with a page_token
-	providers, err := handler.GetCachedProviders(r)
+	// Determine who is making the request, unauthenticated users get the default feed
+	var user *models.User
+	s, err := handler.SessionManager.GetSession(r)
 	if err == nil {
-		// No error means we successfuly found providers in cache
-		handler.getPosts(w, providers)
+		// Get user associate with the session
+		username, ok := s.Get("username").(string)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Retrieve the user from the database
+		u, _, err := handler.Driver.GetUser(username)
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Unable to retrieve user %v from database: %v", username, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		user = &u
+	}
+	owner := handler.pageTokenOwner(r, s)
+
+	// An explicitly requested strategy must exist, a stored one that no longer does falls back to the default
+	if name := r.FormValue("strategy"); name != "" {
+		if _, err := ranking.StrategyFor(name); err != nil {
+			http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Sources that are slower than this are left out of the page rather than holding it up
+	ctx, cancel := context.WithTimeout(r.Context(), handler.ProviderTimeout)
+	defer cancel()
+
+	// First we must determine if the incoming user is making the request with a page_token
+	if r.FormValue("page_token") != "" {
+		set, t, err := handler.GetCachedProviders(ctx, r, owner, user)
+		if err != nil {
+			http.Error(w, buildJSONError(err.Error()), pageTokenErrorCode(err))
+			return
+		}
+
+		handler.getPosts(r.Context(), w, set, owner, handler.rankingStrategy(r, user, t.Strategy), t.Seed)
 		return
 	}
 
 	// Otherwise we need to create new content providers
-	s, err := handler.SessionManager.GetSession(r)
-	if err != nil {
-		// Session could not be found so get the posts for a generic user
-		providers = handler.getDefaultProviders(r)
-		handler.getPosts(w, providers)
-		return
+	var set providerSet
+	if user == nil {
+		set = handler.getDefaultProviders(ctx, r)
+	} else {
+		set = handler.getProvidersForUser(ctx, r, *user)
 	}
+	handler.getPosts(r.Context(), w, set, owner, handler.rankingStrategy(r, user, ""), newSeed())
+}
 
-	// Get user associate with the session
-	username := s.Get("username").(string)
+// Determines the name of the strategy to rank posts with. The strategy query parameter takes precedence,
+// followed by the strategy the page token was issued with and then the strategy the user picked.
+func (handler *CoreHandler) rankingStrategy(r *http.Request, user *models.User, tokenStrategy string) string {
+	for _, name := range []string{r.FormValue("strategy"), tokenStrategy} {
+		if name != "" {
+			return name
+		}
+	}
 
-	// Retrieve the user from the database
-	user, _, err := handler.Driver.GetUser(username)
+	if user != nil {
+		name, err := handler.Driver.GetRankingStrategy(user.Username)
+		if err != nil {
+			logging.FromContext(r.Context()).Warnf("Unable to get ranking strategy for user %v: %v", user.Username, err)
+		} else if name != "" {
+			return name
+		}
+	}
+	return ranking.DefaultStrategy
+}
+
+// Produces the seed for the randomness used to rank the first page of a feed
+func newSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// Identifies who page tokens issued for the request belong to. Tokens are bound to the session that
+// requested them, or for unauthenticated requests to a fingerprint of the caller
+func (handler *CoreHandler) pageTokenOwner(r *http.Request, s sessions.Session) string {
+	if s != nil {
+		return handler.Signer.Owner("session", s.SessionID())
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		host = r.RemoteAddr
 	}
+	return handler.Signer.Owner("anonymous", host, r.UserAgent())
+}
 
-	providers = handler.getProvidersForUser(r, user)
-	handler.getPosts(w, providers)
+// Determines the status code to respond with when a page token cannot be used
+func pageTokenErrorCode(err error) int {
+	switch err {
+	case paging.ErrWrongOwner:
+		return http.StatusForbidden
+	case paging.ErrExpiredToken:
+		return http.StatusGone
+	case paging.ErrMalformedToken, paging.ErrInvalidToken:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
 }
 
-// Takes a request object and retrieves associated providers from cache if they exist
-// TODO: Eventually we will need someway to prevent any user from accessing another users posts
-func (handler *CoreHandler) GetCachedProviders(r *http.Request) ([]*ranking.ContentProvider, error) {
-	if token := r.FormValue("page_token"); token != "" {
-		log.Printf("received the following pageToken: %v", token)
-		if p, ok := handler.Cache.Get(token); ok {
-			// TODO: I wondering what the consequens w/ respect to garbage collection when storing a pointer
-			// to a location in the heap in a cache?
-			providers, ok := p.([]*ranking.ContentProvider)
-			if !ok {
-				log.Printf("Data associated to page token: %v malformed", token)
-				return nil, errors.New("malformed paging data")
+// Takes a request object and retrieves the providers described by its page token, provided it was issued
+// to owner. If this instance served the previous page the providers are taken from cache, otherwise they
+// are resumed from the cursors stored in the token. user is nil for unauthenticated requests.
+func (handler *CoreHandler) GetCachedProviders(ctx context.Context, r *http.Request, owner string, user *models.User) (providerSet, paging.Token, error) {
+	logger := logging.FromContext(ctx)
+	token := r.FormValue("page_token")
+	t, err := handler.Signer.Decode(token, owner)
+	if err != nil {
+		logger.Infof("Unable to use page token: %v", err)
+		return providerSet{}, t, err
+	}
+
+	if p, ok := handler.Cache.Get(token); ok {
+		// Providers are advanced as they are read so they can only be used once
+		handler.Cache.Delete(token)
+		if providers, ok := p.([]*ranking.ContentProvider); ok {
+			// Posts may have been marked seen or muted and boosts changed since the previous page
+			filter, booster := handler.postFilter(ctx, user), handler.postBooster(ctx, user)
+			for _, provider := range providers {
+				if filter != nil {
+					provider.SetFilter(ctx, filter)
+				}
+				provider.SetBooster(booster)
 			}
+			metrics.PageTokenCacheLookup(true)
+			return providerSet{providers: providers, degraded: []string{}}, t, nil
+		}
+		logger.Errorf("Data associated to page token: %v malformed", t.Nonce)
+	}
+
+	metrics.PageTokenCacheLookup(false)
+	logger.Debugf("Providers not found in cache, resuming from page token")
+	return handler.resumeProviders(ctx, t, user), t, nil
+}
+
+// Recreates the content providers from the cursors in a page token
+func (handler *CoreHandler) resumeProviders(ctx context.Context, t paging.Token, user *models.User) providerSet {
+	weights := map[string]float64{}
+	if user != nil {
+		weights = handler.getUserWeights(ctx, *user)
+	}
+
+	b := newProviderBuilder(ctx, handler.postFilter(ctx, user), handler.postBooster(ctx, user))
+	for _, cursor := range t.Cursors {
+		// There is nothing left to read from exhausted providers
+		if cursor.NextURL == "" {
+			continue
+		}
 
-			return providers, nil
+		generator, weight, err := handler.resumePageGenerator(cursor, user, weights)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("Unable to resume %v page generator: %v", cursor.Client, err)
+			continue
 		}
+
+		cursor := cursor
+		b.resume(cursor.Source(), weight, generator, &cursor)
 	}
 
-	log.Printf("Providers not found in cache")
-	return nil, errors.New("unable to retrieve content providers from cache")
+	return b.wait()
+}
+
+// Resumes the page generator for a single cursor, along with the weight of its provider for user
+func (handler *CoreHandler) resumePageGenerator(cursor clients.Cursor, user *models.User, weights map[string]float64) (clients.PageGenerator, float64, error) {
+	if cursor.Client == "rss" {
+		if handler.RssClient == nil {
+			return nil, 0, errors.New("rss client not configured")
+		}
+
+		rssWeights := DefaultRssWeights
+		if user != nil {
+			rssWeights = user.PostWeights.RSS
+		}
+
+		generator, err := handler.RssClient.ResumePageGenerator(cursor)
+		return generator, rssWeights[cursor.Group], err
+	}
+
+	client, err := handler.getClient(cursor.Client)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if user == nil {
+		generator, err := client.ResumePageGenerator(models.User{}, cursor)
+		return generator, clients.DefaultWeight(client.Name()), err
+	}
+
+	generator, err := client.ResumePageGenerator(*user, cursor)
+	return generator, getWeight(client.Name(), weights), err
+}
+
+// Adds source to the sorted list of sources unless it is already there
+func appendSource(sources []string, source string) []string {
+	i := sort.SearchStrings(sources, source)
+	if i < len(sources) && sources[i] == source {
+		return sources
+	}
+	sources = append(sources, "")
+	copy(sources[i+1:], sources[i:])
+	sources[i] = source
+	return sources
 }
 
 // Responds to a request to /v1/posts using the given content providers
 // Also generates a new paging token where in the requesting user can access the next set of posts
-func (handler *CoreHandler) getPosts(w http.ResponseWriter, providers []*ranking.ContentProvider) {
-	posts := ranking.GetPosts(providers, pageSize)
-	pageToken := handler.getNextPagingToken()
-	handler.Cache.Set(pageToken, providers, cache.DefaultExpiration)
-
-	log.Printf("Received %v posts from content providers", len(posts))
-	log.Printf("Next paging token: %v", pageToken)
-	res, err := json.Marshal(PostsResponse{posts, pageToken})
+func (handler *CoreHandler) getPosts(ctx context.Context, w http.ResponseWriter, set providerSet, owner, strategyName string, seed int64) {
+	logger := logging.FromContext(ctx)
+	strategy, err := ranking.StrategyFor(strategyName)
+	if err != nil {
+		logger.Warnf("Unable to rank posts with strategy %v, using %v instead: %v", strategyName, ranking.DefaultStrategy, err)
+		strategyName = ranking.DefaultStrategy
+		strategy, _ = ranking.StrategyFor(strategyName)
+	}
+
+	// The seed and time are logged so that a page someone reports can be ranked again in the same order
+	now := time.Now()
+	logger.Infof("Ranking posts with strategy %v, seed %v and time %v", strategyName, seed, now.Format(time.RFC3339Nano))
+	ranker := ranking.NewRanker(strategy, seed)
+	ranker.Now = func() time.Time { return now }
+	// Sources whose next page is slower than this are paused and retried on the next page rather than
+	// holding this one up. First pages may have used up the request's timeout so ranking gets its own.
+	rankCtx, cancel := context.WithTimeout(ctx, handler.ProviderTimeout)
+	defer cancel()
+	posts := ranker.GetPosts(rankCtx, set.providers, pageSize)
+
+	degraded := set.degraded
+	failed, paused := false, false
+	t := paging.Token{
+		Cursors:  make([]clients.Cursor, 0, len(set.providers)+len(set.pending)),
+		Strategy: strategyName,
+		// Each page is seeded from the page before it so a whole feed can be reproduced from its first seed
+		Seed: ranker.Rand.Int63(),
+	}
+	for _, p := range set.providers {
+		cursor := p.Cursor()
+		t.Cursors = append(t.Cursors, cursor)
+		if p.Err() != nil {
+			failed = true
+			degraded = appendSource(degraded, cursor.Source())
+		}
+		paused = paused || p.Paused()
+	}
+	// Sources that were not ready in time are retried from where they would have started
+	t.Cursors = append(t.Cursors, set.pending...)
+	// Providers resumed from the token are ranked in the same order as the ones that were cached
+	sortCursors(t.Cursors)
+
+	pageToken, err := handler.Signer.Encode(t, owner, pageTokenLifetime)
+	if err != nil {
+		logger.Errorf("Unable to encode page token: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Failed and paused providers do not fetch again and sources that were not ready in time have no
+	// provider, so the next page is resumed from the token to retry them
+	if !failed && !paused && len(set.pending) == 0 {
+		handler.Cache.Set(pageToken, set.providers, cache.DefaultExpiration)
+	}
+
+	logger.Debugf("Received %v posts from content providers", len(posts))
+	res, err := json.Marshal(PostsResponse{posts, pageToken, degraded})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -2,13 +2,26 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/paging"
+	"github.com/iced-mocha/core/ranking"
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
+	"github.com/patrickmn/go-cache"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -37,15 +50,80 @@ func (suite *HandlersTestSuite) SetupSuite() {
 
 	manager := &MockManager{}
 	m := &MockDriver{}
-	suite.handler = CoreHandler{Driver: m, SessionManager: manager}
+	key, err := paging.NewKey()
+	suite.Nil(err)
+	suite.handler = CoreHandler{
+		Driver:          m,
+		SessionManager:  manager,
+		Cache:           cache.New(time.Minute, time.Minute),
+		Signer:          paging.NewSigner(key),
+		Clients:         []clients.Client{&MockClient{}},
+		ProviderTimeout: time.Second,
+		SeenRetention:   time.Hour,
+	}
 
 	// In order to test using path params we need to run a server and send requests to it
 	suite.router = mux.NewRouter()
 	suite.router.HandleFunc("/v1/users/{userID}/authorize/{type}", suite.handler.UpdateAccountAuth).Methods(http.MethodPost)
 	suite.router.HandleFunc("/v1/users/{userID}/weights", suite.handler.UpdateWeights).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/strategy", suite.handler.UpdateRankingStrategy).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/seen", suite.handler.MarkSeen).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/saved", suite.handler.SavePost).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/saved", suite.handler.DeleteSavedPost).Methods(http.MethodDelete)
+	suite.router.HandleFunc("/v1/users/{userID}/saved", suite.handler.GetSavedPosts).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/users/{userID}/filters", suite.handler.GetMuteFilters).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/users/{userID}/filters", suite.handler.InsertMuteFilter).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/filters/{filterID}", suite.handler.DeleteMuteFilter).Methods(http.MethodDelete)
+	suite.router.HandleFunc("/v1/users/{userID}/boosts", suite.handler.GetBoosts).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/users/{userID}/boosts", suite.handler.InsertBoost).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/boosts/{boostID}", suite.handler.DeleteBoost).Methods(http.MethodDelete)
+	suite.router.HandleFunc("/v1/users/{userID}/rss", suite.handler.UpdateRssFeeds).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/import", suite.handler.ImportRssFeeds).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/export", suite.handler.ExportRssFeeds).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/{group}", suite.handler.GetRssGroup).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/{group}", suite.handler.PutRssGroup).Methods(http.MethodPut)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/{group}", suite.handler.PatchRssGroup).Methods(http.MethodPatch)
+	suite.router.HandleFunc("/v1/users/{userID}/rss/{group}", suite.handler.DeleteRssGroup).Methods(http.MethodDelete)
 	suite.router.HandleFunc("/v1/users/{userID}/accounts/{type}", suite.handler.DeleteLinkedAccount).Methods(http.MethodDelete)
 	suite.router.HandleFunc("/v1/users", suite.handler.InsertUser).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/users", suite.handler.GetUser).Methods(http.MethodGet)
 	suite.router.HandleFunc("/v1/login", suite.handler.Login).Methods(http.MethodPost)
+	suite.router.HandleFunc("/v1/posts", suite.handler.GetPosts).Methods(http.MethodGet)
+	suite.router.HandleFunc("/v1/health/clients", suite.handler.GetClientHealth).Methods(http.MethodGet)
+}
+
+// Helper for requesting a page of posts from the given router
+func (suite *HandlersTestSuite) getPosts(router http.Handler, pageToken string, modifiers ...func(*http.Request)) (int, PostsResponse) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/posts?page_token="+url.QueryEscape(pageToken), nil)
+	suite.Nil(err)
+	for _, modify := range modifiers {
+		modify(r)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var resp PostsResponse
+	if w.Code == http.StatusOK {
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w.Code, resp
+}
+
+// Produces the ids of the posts that should appear on the given page of the mock client
+func expectedPostIDs(page int) []string {
+	ids := []string{}
+	for i := page * pageSize; i < (page+1)*pageSize && i < mockPages*mockPageSize; i++ {
+		ids = append(ids, fmt.Sprintf("%v-%v", i/mockPageSize, i%mockPageSize))
+	}
+	return ids
+}
+
+func postIDs(resp PostsResponse) []string {
+	ids := []string{}
+	for _, p := range resp.Posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
 }
 
 func addValidSession(r *http.Request) {
@@ -302,6 +380,790 @@ func (suite *HandlersTestSuite) TestUpdateWeights() {
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
+func (suite *HandlersTestSuite) TestGetUser() {
+	// Without a session there is no user to get
+	r, err := http.NewRequest(http.MethodGet, "/v1/users", nil)
+	suite.Nil(err)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	suite.Equal(http.StatusUnauthorized, w.Code)
+
+	r, err = http.NewRequest(http.MethodGet, "/v1/users", nil)
+	suite.Nil(err)
+	addValidSession(r)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	suite.Equal(http.StatusOK, w.Code)
+
+	// No credentials may appear anywhere in the response
+	body := w.Body.String()
+	for _, credential := range []string{
+		mockPasswordHash, mockRedditToken, mockRedditRefreshToken, mockTwitterToken, mockTwitterSecret,
+	} {
+		suite.NotContains(body, credential)
+	}
+
+	var fields map[string]interface{}
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &fields))
+	for _, field := range []string{
+		"password", "reddit-auth-token", "reddit-refresh-token", "twitter-auth-token", "twitter-secret", "facebook-auth-token",
+	} {
+		suite.NotContains(fields, field)
+	}
+
+	var user UserView
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &user))
+	suite.Equal(UserView{
+		ID:               "id",
+		Username:         "userID",
+		RedditUsername:   "redditor",
+		RedditConnected:  true,
+		TwitterUsername:  "tweeter",
+		TwitterConnected: true,
+		PostWeights:      models.Weights{Reddit: 20.0},
+		RssGroups:        map[string][]string{"news": []string{"http://example.com/rss"}},
+		RankingStrategy:  "blend",
+	}, user)
+}
+
+func (suite *HandlersTestSuite) TestUpdateRankingStrategy() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer driver.UpdateRankingStrategy("userID", "")
+
+	updateStrategy := func(user, body string, modifiers ...func(*http.Request)) int {
+		r, err := http.NewRequest(http.MethodPost, "/v1/users/"+user+"/strategy", bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	suite.Equal(http.StatusOK, updateStrategy("userID", `{"strategy": "chronological"}`, addValidSession))
+	strategy, _ := driver.GetRankingStrategy("userID")
+	suite.Equal("chronological", strategy)
+
+	// Only existing strategies can be picked
+	suite.Equal(http.StatusBadRequest, updateStrategy("userID", `{"strategy": "alphabetical"}`, addValidSession))
+	suite.Equal(http.StatusBadRequest, updateStrategy("userID", `"not json"}`, addValidSession))
+	strategy, _ = driver.GetRankingStrategy("userID")
+	suite.Equal("chronological", strategy)
+
+	suite.Equal(http.StatusUnauthorized, updateStrategy("userID", `{"strategy": "popularity"}`))
+	suite.Equal(http.StatusForbidden, updateStrategy("user", `{"strategy": "popularity"}`, addValidSession))
+
+	// The strategy is reported along with the rest of the user
+	r, err := http.NewRequest(http.MethodGet, "/v1/users", nil)
+	suite.Nil(err)
+	addValidSession(r)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	var user UserView
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &user))
+	suite.Equal("chronological", user.RankingStrategy)
+}
+
+func (suite *HandlersTestSuite) TestGetPostsPageToken() {
+	// The first page should not require a token
+	code, first := suite.getPosts(suite.router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(0), postIDs(first))
+	suite.NotEmpty(first.PageToken)
+
+	// A second instance of core sharing the signing key should be able to resume the feed
+	replica := suite.handler
+	replica.Cache = cache.New(time.Minute, time.Minute)
+	replicaRouter := mux.NewRouter()
+	replicaRouter.HandleFunc("/v1/posts", replica.GetPosts).Methods(http.MethodGet)
+
+	code, second := suite.getPosts(replicaRouter, first.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(1), postIDs(second))
+
+	// The instance that served the first page should produce the same page from its cache
+	code, cached := suite.getPosts(suite.router, first.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(1), postIDs(cached))
+
+	// Once the cached providers have been used the token should still resume the same page
+	code, resumed := suite.getPosts(suite.router, first.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(1), postIDs(resumed))
+
+	// Continuing past the last page should produce no more posts
+	code, third := suite.getPosts(replicaRouter, second.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(2), postIDs(third))
+
+	code, fourth := suite.getPosts(replicaRouter, third.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Empty(fourth.Posts)
+}
+
+func (suite *HandlersTestSuite) TestGetPostsStrategy() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer driver.UpdateRankingStrategy("userID", "")
+
+	withStrategy := func(strategy string) func(*http.Request) {
+		return func(r *http.Request) { r.URL.RawQuery += "&strategy=" + strategy }
+	}
+	// Decodes a page token issued for a request with the given modifiers
+	decode := func(token string, modifiers ...func(*http.Request)) paging.Token {
+		r, err := http.NewRequest(http.MethodGet, "/v1/posts", nil)
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		s, _ := suite.handler.SessionManager.GetSession(r)
+		t, err := suite.handler.Signer.Decode(token, suite.handler.pageTokenOwner(r, s))
+		suite.Nil(err)
+		return t
+	}
+	tokenStrategy := func(token string, modifiers ...func(*http.Request)) string {
+		return decode(token, modifiers...).Strategy
+	}
+
+	code, resp := suite.getPosts(suite.router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("blend", tokenStrategy(resp.PageToken))
+
+	code, resp = suite.getPosts(suite.router, "", withStrategy("round-robin"))
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(0), postIDs(resp))
+	suite.Equal("round-robin", tokenStrategy(resp.PageToken))
+
+	// Later pages keep the strategy of the first unless another is requested
+	code, next := suite.getPosts(suite.router, resp.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(1), postIDs(next))
+	suite.Equal("round-robin", tokenStrategy(next.PageToken))
+
+	// Every page is seeded from the page before it
+	suite.NotZero(decode(resp.PageToken).Seed)
+	suite.NotEqual(decode(resp.PageToken).Seed, decode(next.PageToken).Seed)
+
+	code, next = suite.getPosts(suite.router, resp.PageToken, withStrategy("popularity"))
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("popularity", tokenStrategy(next.PageToken))
+
+	code, _ = suite.getPosts(suite.router, "", withStrategy("alphabetical"))
+	suite.Equal(http.StatusBadRequest, code)
+
+	// Users get the strategy they picked by default
+	suite.Nil(driver.UpdateRankingStrategy("userID", "chronological"))
+	code, resp = suite.getPosts(suite.router, "", addValidSession)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("chronological", tokenStrategy(resp.PageToken, addValidSession))
+
+	code, resp = suite.getPosts(suite.router, "", addValidSession, withStrategy("blend"))
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("blend", tokenStrategy(resp.PageToken, addValidSession))
+}
+
+func (suite *HandlersTestSuite) TestMarkSeen() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.seen = nil }()
+
+	markSeen := func(user string, ids []string, modifiers ...func(*http.Request)) int {
+		body, err := json.Marshal(SeenRequest{ids})
+		suite.Nil(err)
+		r, err := http.NewRequest(http.MethodPost, "/v1/users/"+user+"/seen", bytes.NewBuffer(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Every post of the first mock page is seen along with one post of the second
+	seen := []string{"1-3"}
+	for i := 0; i < mockPageSize; i++ {
+		seen = append(seen, fmt.Sprintf("0-%v", i))
+	}
+	suite.Equal(http.StatusOK, markSeen("userID", seen, addValidSession))
+
+	suite.Equal(http.StatusUnauthorized, markSeen("userID", seen))
+	suite.Equal(http.StatusForbidden, markSeen("user", seen, addValidSession))
+	suite.Equal(http.StatusBadRequest, markSeen("userID", make([]string, maxSeenPerRequest+1), addValidSession))
+
+	// Seen posts are left out and pages that only have seen posts are skipped
+	expected := []string{}
+	for i := mockPageSize; len(expected) < pageSize; i++ {
+		if id := fmt.Sprintf("%v-%v", i/mockPageSize, i%mockPageSize); id != "1-3" {
+			expected = append(expected, id)
+		}
+	}
+	code, resp := suite.getPosts(suite.router, "", addValidSession)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expected, postIDs(resp))
+
+	// Posts seen after the page token was issued are left out of later pages
+	suite.Equal(http.StatusOK, markSeen("userID", []string{"2-15"}, addValidSession))
+	code, resp = suite.getPosts(suite.router, resp.PageToken, addValidSession)
+	suite.Equal(http.StatusOK, code)
+	suite.NotContains(postIDs(resp), "2-15")
+	suite.Contains(postIDs(resp), "2-16")
+
+	// Anonymous feeds are unaffected
+	code, resp = suite.getPosts(suite.router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(0), postIDs(resp))
+
+	// Posts are only remembered for the retention
+	driver.MarkSeen("userID", []string{"expired"}, time.Now().Add(-2*time.Hour))
+	suite.Equal(http.StatusOK, markSeen("userID", []string{}, addValidSession))
+	suite.NotContains(driver.seen["userID"], "expired")
+	suite.Contains(driver.seen["userID"], "1-3")
+}
+
+func (suite *HandlersTestSuite) TestSavedPosts() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.saved = nil }()
+
+	send := func(method, target, body string, modifiers ...func(*http.Request)) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+	getSaved := func(query string) SavedPostsResponse {
+		w := send(http.MethodGet, "/v1/users/userID/saved"+query, "", addValidSession)
+		suite.Equal(http.StatusOK, w.Code)
+		var resp SavedPostsResponse
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	savedIDs := func(resp SavedPostsResponse) []string {
+		ids := []string{}
+		for _, p := range resp.Posts {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		w := send(http.MethodPost, "/v1/users/userID/saved", `{"id": "`+id+`", "title": "Post `+id+`", "score": 3}`, addValidSession)
+		suite.Equal(http.StatusOK, w.Code)
+	}
+
+	suite.Equal(http.StatusUnauthorized, send(http.MethodPost, "/v1/users/userID/saved", `{"id": "d"}`).Code)
+	suite.Equal(http.StatusForbidden, send(http.MethodGet, "/v1/users/user/saved", "", addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/saved", `{"title": "No id"}`, addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/saved", `"not json"}`, addValidSession).Code)
+
+	// Saved posts are paged through with the cursor of the previous response
+	first := getSaved("?limit=2")
+	suite.Len(first.Posts, 2)
+	suite.NotEmpty(first.NextCursor)
+	suite.Equal("Post "+first.Posts[0].ID, first.Posts[0].Title)
+	suite.Equal(3, first.Posts[0].Score)
+
+	second := getSaved("?limit=2&cursor=" + first.NextCursor)
+	suite.Len(second.Posts, 1)
+	suite.Empty(second.NextCursor)
+	suite.ElementsMatch([]string{"a", "b", "c"}, append(savedIDs(first), savedIDs(second)...))
+
+	suite.Equal(http.StatusBadRequest, send(http.MethodGet, "/v1/users/userID/saved?cursor=nonsense", "", addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodGet, "/v1/users/userID/saved?limit=0", "", addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodGet, "/v1/users/userID/saved?limit=1000", "", addValidSession).Code)
+
+	suite.Equal(http.StatusOK, send(http.MethodDelete, "/v1/users/userID/saved?id=b", "", addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodDelete, "/v1/users/userID/saved?id=b", "", addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodDelete, "/v1/users/userID/saved", "", addValidSession).Code)
+	suite.ElementsMatch([]string{"a", "c"}, savedIDs(getSaved("")))
+}
+
+func (suite *HandlersTestSuite) TestMuteFilters() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.filters = nil }()
+
+	send := func(method, target, body string, modifiers ...func(*http.Request)) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+	addFilter := func(body string) storage.MuteFilter {
+		w := send(http.MethodPost, "/v1/users/userID/filters", body, addValidSession)
+		suite.Equal(http.StatusCreated, w.Code)
+		var f storage.MuteFilter
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &f))
+		suite.NotEmpty(f.ID)
+		return f
+	}
+
+	// Every post of the first mock page is muted along with one post of the second
+	regex := addFilter(`{"type": "regex", "value": "^post 0-"}`)
+	keyword := addFilter(`{"type": "keyword", "value": " 1-3 "}`)
+	suite.Equal("1-3", keyword.Value)
+
+	for _, body := range []string{
+		`{"type": "regex", "value": "(unclosed"}`,
+		`{"type": "colour", "value": "blue"}`,
+		`{"type": "keyword", "value": ""}`,
+		`"not json"}`,
+	} {
+		suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/filters", body, addValidSession).Code, body)
+	}
+	suite.Equal(http.StatusUnauthorized, send(http.MethodPost, "/v1/users/userID/filters", `{"type": "keyword", "value": "a"}`).Code)
+	suite.Equal(http.StatusForbidden, send(http.MethodGet, "/v1/users/user/filters", "", addValidSession).Code)
+
+	w := send(http.MethodGet, "/v1/users/userID/filters", "", addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	var resp MuteFiltersResponse
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	suite.Equal([]storage.MuteFilter{regex, keyword}, resp.Filters)
+
+	// Muted posts are left out and the page is filled from the pages that follow
+	expected := []string{}
+	for i := mockPageSize; len(expected) < pageSize; i++ {
+		if id := fmt.Sprintf("%v-%v", i/mockPageSize, i%mockPageSize); id != "1-3" {
+			expected = append(expected, id)
+		}
+	}
+	code, posts := suite.getPosts(suite.router, "", addValidSession)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expected, postIDs(posts))
+
+	// Anonymous feeds are unaffected
+	code, posts = suite.getPosts(suite.router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(0), postIDs(posts))
+
+	suite.Equal(http.StatusOK, send(http.MethodDelete, "/v1/users/userID/filters/"+regex.ID, "", addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodDelete, "/v1/users/userID/filters/"+regex.ID, "", addValidSession).Code)
+	code, posts = suite.getPosts(suite.router, "", addValidSession)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("0-0", posts.Posts[0].ID)
+}
+
+func (suite *HandlersTestSuite) TestProvidersOrderedBySource() {
+	// Later sources are ready first but are still ranked after earlier ones
+	b := newProviderBuilder(context.Background(), nil, nil)
+	for i, name := range []string{"a", "b", "c"} {
+		client := &MockClient{name: name, delay: time.Duration(3-i) * 10 * time.Millisecond}
+		generator, err := client.GetDefaultPageGenerator()
+		suite.Nil(err)
+		b.add(name, mockWeight, generator)
+	}
+
+	set := b.wait()
+	sources := []string{}
+	for _, p := range set.providers {
+		sources = append(sources, p.Cursor().Source())
+	}
+	suite.Equal([]string{"a", "b", "c"}, sources)
+}
+
+func (suite *HandlersTestSuite) TestGetPostsDegradedSources() {
+	code, resp := suite.getPosts(suite.router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal([]string{}, resp.DegradedSources)
+
+	// A source slower than the timeout should be left out without holding up the others
+	suite.Nil(clients.Register("slow", func(conf clients.Config) (clients.Client, error) { return &MockClient{name: "slow"}, nil }, mockWeight))
+	slow := suite.handler
+	slow.Cache = cache.New(time.Minute, time.Minute)
+	slow.ProviderTimeout = 100 * time.Millisecond
+	slow.Clients = []clients.Client{&MockClient{}, &MockClient{name: "slow", delay: time.Minute}}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/posts", slow.GetPosts).Methods(http.MethodGet)
+
+	start := time.Now()
+	code, resp = suite.getPosts(router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.True(time.Since(start) < 10*time.Second)
+	suite.Equal(expectedPostIDs(0), postIDs(resp))
+	suite.Equal([]string{"slow"}, resp.DegradedSources)
+	_, cached := slow.Cache.Get(resp.PageToken)
+	suite.False(cached)
+
+	// The slow source is retried from its first page once it responds in time
+	fast := slow
+	fast.Clients = []clients.Client{&MockClient{}, &MockClient{name: "slow"}}
+	router = mux.NewRouter()
+	router.HandleFunc("/v1/posts", fast.GetPosts).Methods(http.MethodGet)
+
+	code, second := suite.getPosts(router, resp.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal([]string{}, second.DegradedSources)
+	suite.Len(second.Posts, pageSize)
+	suite.Contains(postIDs(second), "slow-0-0")
+}
+
+func (suite *HandlersTestSuite) TestGetPostsFailedSource() {
+	failing := &MockClient{name: "failing", err: errors.New("unavailable")}
+	handler := suite.handler
+	handler.Cache = cache.New(time.Minute, time.Minute)
+	handler.Clients = []clients.Client{&MockClient{}, failing}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/posts", handler.GetPosts).Methods(http.MethodGet)
+
+	code, first := suite.getPosts(router, "")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(expectedPostIDs(0), postIDs(first))
+	suite.Equal([]string{"failing"}, first.DegradedSources)
+
+	// The failed source is retried when the next page is requested
+	failing.err = nil
+	code, second := suite.getPosts(router, first.PageToken)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal([]string{}, second.DegradedSources)
+	suite.Len(second.Posts, pageSize)
+}
+
+func (suite *HandlersTestSuite) TestBoosts() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.boosts = nil }()
+
+	suite.Nil(clients.Register("rival", func(conf clients.Config) (clients.Client, error) { return &MockClient{name: "rival"}, nil }, mockWeight))
+	handler := suite.handler
+	handler.Cache = cache.New(time.Minute, time.Minute)
+	handler.Clients = []clients.Client{&MockClient{}, &MockClient{name: "rival"}}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/posts", handler.GetPosts).Methods(http.MethodGet)
+	router.HandleFunc("/v1/users/{userID}/boosts", handler.GetBoosts).Methods(http.MethodGet)
+	router.HandleFunc("/v1/users/{userID}/boosts", handler.InsertBoost).Methods(http.MethodPost)
+	router.HandleFunc("/v1/users/{userID}/boosts/{boostID}", handler.DeleteBoost).Methods(http.MethodDelete)
+
+	send := func(method, target, body string, modifiers ...func(*http.Request)) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	rivalPosts := func() int {
+		code, posts := suite.getPosts(router, "", addValidSession)
+		suite.Equal(http.StatusOK, code)
+		suite.Len(posts.Posts, pageSize)
+		n := 0
+		for _, p := range posts.Posts {
+			if strings.HasPrefix(p.ID, "rival-") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Both sources are equally weighted so their posts are mixed
+	suite.True(rivalPosts() < pageSize)
+
+	w := send(http.MethodPost, "/v1/users/userID/boosts", `{"type": "keyword", "value": " Rival ", "multiplier": 10}`, addValidSession)
+	suite.Equal(http.StatusCreated, w.Code)
+	var boost storage.Boost
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &boost))
+	suite.NotEmpty(boost.ID)
+	suite.Equal(storage.Boost{ID: boost.ID, Type: ranking.BoostKeyword, Value: "Rival", Multiplier: 10}, boost)
+
+	for _, body := range []string{
+		`{"type": "keyword", "value": "rival"}`,
+		`{"type": "keyword", "value": "rival", "multiplier": 11}`,
+		`{"type": "domain", "value": "localhost", "multiplier": 2}`,
+		`{"type": "author", "value": "someone", "multiplier": 2}`,
+		`"not json"}`,
+	} {
+		suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/boosts", body, addValidSession).Code, body)
+	}
+	suite.Equal(http.StatusUnauthorized, send(http.MethodPost, "/v1/users/userID/boosts", `{"type": "keyword", "value": "a", "multiplier": 2}`).Code)
+	suite.Equal(http.StatusForbidden, send(http.MethodGet, "/v1/users/user/boosts", "", addValidSession).Code)
+
+	w = send(http.MethodGet, "/v1/users/userID/boosts", "", addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	var resp BoostsResponse
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	suite.Equal([]storage.Boost{boost}, resp.Boosts)
+
+	// Boosted posts outrank the posts of the other source published at the same time
+	suite.Equal(pageSize, rivalPosts())
+
+	suite.Equal(http.StatusOK, send(http.MethodDelete, "/v1/users/userID/boosts/"+boost.ID, "", addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodDelete, "/v1/users/userID/boosts/"+boost.ID, "", addValidSession).Code)
+	suite.True(rivalPosts() < pageSize)
+}
+
+func (suite *HandlersTestSuite) TestRssGroups() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.rss = nil }()
+
+	send := func(method, target, body string, modifiers ...func(*http.Request)) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+	group := func(w *httptest.ResponseRecorder) storage.RssGroup {
+		var g storage.RssGroup
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &g))
+		return g
+	}
+
+	// Feeds are normalized and duplicates left out before they are saved
+	w := send(http.MethodPut, "/v1/users/userID/rss/tech", `{"feeds": ["example.com/rss", "http://EXAMPLE.com/rss#top", "https://other.com/feed"]}`, addValidSession)
+	suite.Equal(http.StatusCreated, w.Code)
+	tech := storage.RssGroup{Name: "tech", Feeds: []string{"http://example.com/rss", "https://other.com/feed"}, Weight: storage.DefaultRssGroupWeight}
+	suite.Equal(tech, group(w))
+
+	w = send(http.MethodGet, "/v1/users/userID/rss/tech", "", addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(tech, group(w))
+
+	// Replacing the feeds keeps the weight unless one is given
+	w = send(http.MethodPut, "/v1/users/userID/rss/tech", `{"feeds": ["https://other.com/feed"]}`, addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	tech.Feeds = []string{"https://other.com/feed"}
+	suite.Equal(tech, group(w))
+
+	w = send(http.MethodPatch, "/v1/users/userID/rss/tech", `{"weight": 12.5}`, addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	tech.Weight = 12.5
+	suite.Equal(tech, group(w))
+	suite.Equal(tech, driver.rss["userID"]["tech"])
+
+	for method, body := range map[string]string{
+		http.MethodPut:   `{"weight": 10}`,
+		http.MethodPatch: `{"feeds": ["ftp://example.com/rss"]}`,
+	} {
+		suite.Equal(http.StatusBadRequest, send(method, "/v1/users/userID/rss/tech", body, addValidSession).Code, body)
+	}
+	suite.Equal(http.StatusBadRequest, send(http.MethodPatch, "/v1/users/userID/rss/tech", `{"weight": -1}`, addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPut, "/v1/users/userID/rss/tech", `"not json"}`, addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPut, "/v1/users/userID/rss/"+strings.Repeat("a", 65), `{"feeds": []}`, addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodPatch, "/v1/users/userID/rss/missing", `{"weight": 1}`, addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodGet, "/v1/users/userID/rss/missing", "", addValidSession).Code)
+	suite.Equal(http.StatusUnauthorized, send(http.MethodGet, "/v1/users/userID/rss/tech", "").Code)
+	suite.Equal(http.StatusForbidden, send(http.MethodDelete, "/v1/users/user/rss/tech", "", addValidSession).Code)
+	suite.Equal(tech, driver.rss["userID"]["tech"])
+
+	suite.Equal(http.StatusOK, send(http.MethodDelete, "/v1/users/userID/rss/tech", "", addValidSession).Code)
+	suite.Equal(http.StatusNotFound, send(http.MethodDelete, "/v1/users/userID/rss/tech", "", addValidSession).Code)
+
+	// Replacing every group at once validates feeds in the same way
+	suite.Equal(http.StatusOK, send(http.MethodPost, "/v1/users/userID/rss", `{"news": ["bbc.co.uk/rss", "http://bbc.co.uk/rss"]}`, addValidSession).Code)
+	suite.Equal([]string{"http://bbc.co.uk/rss"}, driver.rss["userID"]["news"].Feeds)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/rss", `{"news": ["mailto:someone@example.com"]}`, addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/rss", `{"": []}`, addValidSession).Code)
+}
+
+func (suite *HandlersTestSuite) TestRssImportExport() {
+	driver := suite.handler.Driver.(*MockDriver)
+	defer func() { driver.rss = nil }()
+
+	send := func(method, target, body string, modifiers ...func(*http.Request)) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		suite.Nil(err)
+		for _, modify := range modifiers {
+			modify(r)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+	opml := `<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="news">
+      <outline type="rss" text="BBC" xmlUrl="http://feeds.bbci.co.uk/news/rss.xml"/>
+      <outline type="rss" text="Duplicate" xmlUrl="http://EXAMPLE.com/rss"/>
+    </outline>
+    <outline text="tech">
+      <outline type="rss" text="Go blog" xmlUrl="https://blog.golang.org/feed.atom"/>
+      <outline type="rss" text="Broken" xmlUrl="ftp://example.com/feed"/>
+    </outline>
+  </body>
+</opml>`
+	importFeeds := func(query string) RssImportResponse {
+		w := send(http.MethodPost, "/v1/users/userID/rss/import"+query, opml, addValidSession)
+		suite.Equal(http.StatusOK, w.Code)
+		var resp RssImportResponse
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		suite.Equal([]string{"ftp://example.com/feed"}, resp.Skipped)
+		return resp
+	}
+
+	// Merging adds the feeds of the document to the user's groups, leaving out feeds they already have
+	resp := importFeeds("")
+	expected := map[string][]string{
+		"news": []string{"http://example.com/rss", "http://feeds.bbci.co.uk/news/rss.xml"},
+		"tech": []string{"https://blog.golang.org/feed.atom"},
+	}
+	suite.Equal(expected, resp.RssGroups)
+	suite.Equal(expected["news"], driver.rss["userID"]["news"].Feeds)
+	suite.Equal(expected["tech"], driver.rss["userID"]["tech"].Feeds)
+
+	// Replacing leaves out the groups and feeds the document does not have
+	resp = importFeeds("?mode=replace")
+	suite.Equal(map[string][]string{
+		"news": []string{"http://feeds.bbci.co.uk/news/rss.xml", "http://example.com/rss"},
+		"tech": []string{"https://blog.golang.org/feed.atom"},
+	}, resp.RssGroups)
+
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/rss/import?mode=append", opml, addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/rss/import", "<opml><body>", addValidSession).Code)
+	suite.Equal(http.StatusBadRequest, send(http.MethodPost, "/v1/users/userID/rss/import",
+		`<opml><body><outline text="export"/></body></opml>`, addValidSession).Code)
+	suite.Equal(http.StatusUnauthorized, send(http.MethodPost, "/v1/users/userID/rss/import", opml).Code)
+
+	// Exports are made from the groups of the user
+	w := send(http.MethodGet, "/v1/users/userID/rss/export", "", addValidSession)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal("text/x-opml; charset=utf-8", w.Header().Get("Content-Type"))
+	suite.Contains(w.Body.String(), `<outline text="news" title="news">`)
+	suite.Contains(w.Body.String(), `xmlUrl="http://example.com/rss"`)
+	suite.Equal(http.StatusForbidden, send(http.MethodGet, "/v1/users/user/rss/export", "", addValidSession).Code)
+}
+
+func (suite *HandlersTestSuite) TestGetClientHealth() {
+	_, err := clients.NewHTTPClient(clients.Config{Name: mockName})
+	suite.Nil(err)
+
+	r, err := http.NewRequest(http.MethodGet, "/v1/health/clients", nil)
+	suite.Nil(err)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	suite.Equal(http.StatusOK, w.Code)
+
+	var resp ClientHealthResponse
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	suite.Contains(resp.Clients, clients.BreakerStatus{Client: mockName, State: clients.BreakerClosed})
+}
+
+// Helper for requesting the health of core from the given endpoint of handler, after probing its dependencies
+func (suite *HandlersTestSuite) getHealth(handler CoreHandler, endpoint string) (int, HealthResponse) {
+	handler.health = &healthCache{}
+	handler.probeHealth(context.Background())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", handler.Healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", handler.Readyz).Methods(http.MethodGet)
+
+	r, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	suite.Nil(err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var resp HealthResponse
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func (suite *HandlersTestSuite) TestHealth() {
+	code, resp := suite.getHealth(suite.handler, "/readyz")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(statusOK, resp.Status)
+	suite.Len(resp.Dependencies, 2)
+	suite.Equal("database", resp.Dependencies[0].Name)
+	suite.Equal("storage", resp.Dependencies[0].Type)
+	suite.Equal(mockName, resp.Dependencies[1].Name)
+	suite.Equal(statusUp, resp.Dependencies[1].Status)
+
+	// Core is still ready when only some clients are down
+	handler := suite.handler
+	handler.Clients = []clients.Client{&MockClient{}, &MockClient{name: "down", pingErr: errors.New("connection refused")}}
+	code, resp = suite.getHealth(handler, "/readyz")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(statusDegraded, resp.Status)
+	suite.Equal(DependencyStatus{Name: "down", Type: "client", Status: statusDown, LatencyMS: resp.Dependencies[1].LatencyMS}, resp.Dependencies[1])
+
+	// But not without a database
+	handler.Driver = &MockDriver{pingErr: errors.New("database is locked")}
+	code, resp = suite.getHealth(handler, "/readyz")
+	suite.Equal(http.StatusServiceUnavailable, code)
+	suite.Equal(statusUnavailable, resp.Status)
+	suite.Equal(statusDown, resp.Dependencies[0].Status)
+
+	// Core is alive regardless of its dependencies, which are not checked
+	handler.Clients = []clients.Client{&MockClient{pingErr: errors.New("not called")}}
+	code, resp = suite.getHealth(handler, "/healthz")
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(statusOK, resp.Status)
+	suite.Empty(resp.Dependencies)
+	suite.Nil(resp.CheckedAt)
+
+	// Readiness is only served from probes of the dependencies, which are made in the background
+	handler.health = &healthCache{}
+	router := mux.NewRouter()
+	router.HandleFunc("/readyz", handler.Readyz).Methods(http.MethodGet)
+	r, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+	suite.Nil(err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	suite.Equal(http.StatusServiceUnavailable, w.Code)
+
+	handler.Driver = suite.handler.Driver
+	handler.Clients = suite.handler.Clients
+	handler.probeHealth(context.Background())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+func (suite *HandlersTestSuite) TestGetPostsPageTokenOwner() {
+	withUserAgent := func(r *http.Request) { r.Header.Set("User-Agent", "test-agent") }
+
+	// Tokens issued to an unauthenticated caller can be used by the same caller
+	code, anonymous := suite.getPosts(suite.router, "", withUserAgent)
+	suite.Equal(http.StatusOK, code)
+	code, _ = suite.getPosts(suite.router, anonymous.PageToken, withUserAgent)
+	suite.Equal(http.StatusOK, code)
+
+	// But not by a different caller
+	code, _ = suite.getPosts(suite.router, anonymous.PageToken)
+	suite.Equal(http.StatusForbidden, code)
+
+	// Or by a logged in user
+	code, _ = suite.getPosts(suite.router, anonymous.PageToken, withUserAgent, addValidSession)
+	suite.Equal(http.StatusForbidden, code)
+
+	// Tokens issued to a session can only be used by that session
+	code, authenticated := suite.getPosts(suite.router, "", addValidSession)
+	suite.Equal(http.StatusOK, code)
+	code, _ = suite.getPosts(suite.router, authenticated.PageToken, addValidSession)
+	suite.Equal(http.StatusOK, code)
+	code, _ = suite.getPosts(suite.router, authenticated.PageToken)
+	suite.Equal(http.StatusForbidden, code)
+}
+
+func (suite *HandlersTestSuite) TestGetPostsPageTokenInvalid() {
+	// Expired tokens should be gone
+	owner := suite.handler.pageTokenOwner(&http.Request{}, nil)
+	expired, err := suite.handler.Signer.Encode(paging.Token{}, owner, -time.Minute)
+	suite.Nil(err)
+	code, _ := suite.getPosts(suite.router, expired)
+	suite.Equal(http.StatusGone, code)
+
+	// Guessed tokens should be rejected
+	code, _ = suite.getPosts(suite.router, "1")
+	suite.Equal(http.StatusBadRequest, code)
+
+	// Tokens signed by someone else should be rejected
+	key, err := paging.NewKey()
+	suite.Nil(err)
+	forged, err := paging.NewSigner(key).Encode(paging.Token{}, owner, time.Minute)
+	suite.Nil(err)
+	code, _ = suite.getPosts(suite.router, forged)
+	suite.Equal(http.StatusBadRequest, code)
+}
+
 func TestSuite(t *testing.T) {
 	suite.Run(t, new(HandlersTestSuite))
 }
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/logging"
+)
+
+const (
+	// How long each dependency has to respond to a health check
+	healthProbeTimeout = 2 * time.Second
+	// How often dependencies are probed unless health.probe-interval-ms is configured
+	defaultHealthInterval = 15 * time.Second
+)
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+
+	// Every dependency is up
+	statusOK = "ok"
+	// Core can serve requests but some clients are down so feeds are missing posts
+	statusDegraded = "degraded"
+	// Core cannot serve requests
+	statusUnavailable = "unavailable"
+)
+
+// The result of checking a single dependency of core
+type DependencyStatus struct {
+	Name string `json:"name"`
+	// Either storage or client
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency-ms"`
+}
+
+type HealthResponse struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+	// When the dependencies were last probed, unset when they have not been yet
+	CheckedAt *time.Time `json:"checked-at,omitempty"`
+}
+
+// The result of the most recent probe of core's dependencies, shared by every request to /readyz
+type healthCache struct {
+	lock   sync.RWMutex
+	health *HealthResponse
+}
+
+func (c *healthCache) get() (HealthResponse, bool) {
+	if c == nil {
+		return HealthResponse{}, false
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.health == nil {
+		return HealthResponse{}, false
+	}
+	return *c.health, true
+}
+
+func (c *healthCache) set(health HealthResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.health = &health
+}
+
+type ClientHealthResponse struct {
+	Clients []clients.BreakerStatus `json:"clients"`
+}
+
+// Times probe and records its result as the status of the named dependency
+func checkDependency(ctx context.Context, name, kind string, probe func(context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := probe(ctx)
+	s := DependencyStatus{
+		Name:      name,
+		Type:      kind,
+		Status:    statusUp,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		// Errors can name internal hosts so they are only logged
+		logging.Warnf("Health check of %v %v failed: %v", kind, name, err)
+		s.Status = statusDown
+	}
+	return s
+}
+
+// Probes the database and every client concurrently
+func (handler *CoreHandler) checkHealth(ctx context.Context) HealthResponse {
+	probes := map[string]func(context.Context) error{}
+	for _, c := range handler.Clients {
+		probes[c.Name()] = c.Ping
+	}
+	if handler.RssClient != nil {
+		probes[handler.RssClient.Name()] = handler.RssClient.Ping
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	deps := []DependencyStatus{}
+	for name, probe := range probes {
+		wg.Add(1)
+		go func(name string, probe func(context.Context) error) {
+			defer wg.Done()
+			s := checkDependency(ctx, name, "client", probe)
+			lock.Lock()
+			deps = append(deps, s)
+			lock.Unlock()
+		}(name, probe)
+	}
+	storage := checkDependency(ctx, "database", "storage", handler.Driver.Ping)
+	wg.Wait()
+
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+
+	resp := HealthResponse{Status: statusOK, Dependencies: append([]DependencyStatus{storage}, deps...)}
+	clientsUp := 0
+	for _, d := range deps {
+		if d.Status == statusUp {
+			clientsUp++
+		}
+	}
+
+	// Without a database nobody can log in and without any clients there are no posts to serve
+	if storage.Status != statusUp || (len(deps) > 0 && clientsUp == 0) {
+		resp.Status = statusUnavailable
+	} else if clientsUp < len(deps) {
+		resp.Status = statusDegraded
+	}
+	return resp
+}
+
+// Probes the dependencies of core and stores the result for /readyz to serve
+func (handler *CoreHandler) probeHealth(ctx context.Context) {
+	health := handler.checkHealth(ctx)
+	now := time.Now()
+	health.CheckedAt = &now
+	handler.health.set(health)
+}
+
+// Probes the dependencies of core every HealthInterval, never returns. Requests to /readyz are
+// served from the result of the last probe so that they do not each make requests to every dependency.
+func (handler *CoreHandler) MonitorHealth() {
+	for {
+		handler.probeHealth(context.Background())
+		time.Sleep(handler.HealthInterval)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, health interface{}, code int) {
+	res, err := json.Marshal(health)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(res)
+}
+
+/* GET /healthz
+ * Reports that core is alive, which it is as long as it can respond. No dependencies are checked,
+ * use /readyz to decide whether to send core traffic.
+ */
+func (handler *CoreHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthResponse{Status: statusOK, Dependencies: []DependencyStatus{}}, http.StatusOK)
+}
+
+/* GET /readyz
+ * Reports the status of core and each of its dependencies as of the last background probe, failing
+ * with 503 when core cannot serve requests or has not probed its dependencies yet. Core is still
+ * ready when only some of its clients are down.
+ */
+func (handler *CoreHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	health, ok := handler.health.get()
+	if !ok {
+		health = HealthResponse{Status: statusUnavailable, Dependencies: []DependencyStatus{}}
+	}
+
+	code := http.StatusOK
+	if health.Status == statusUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, health, code)
+}
+
+/* GET /v1/health/clients
+ * Reports the circuit breaker state of every client core has talked to
+ */
+func (handler *CoreHandler) GetClientHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, ClientHealthResponse{clients.Breakers()}, http.StatusOK)
+}
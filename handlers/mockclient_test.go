@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/shared/models"
+)
+
+const (
+	mockName     = "mock"
+	mockWeight   = 20.0
+	mockPageSize = 30
+	mockPages    = 3
+)
+
+// A client that serves mockPages pages of mockPageSize posts, pages are addressed by urls of the form page-<n>.
+// The ids of posts from clients given a name are prefixed with it.
+type MockClient struct {
+	// Defaults to mockName
+	name string
+	// How long each page takes to fetch
+	delay time.Duration
+	// When set every page fails with this error
+	err error
+	// When set Ping fails with this error
+	pingErr error
+}
+
+func (m *MockClient) GetPageGenerator(user models.User) (clients.PageGenerator, error) {
+	return m.GetDefaultPageGenerator()
+}
+
+func (m *MockClient) GetDefaultPageGenerator() (clients.PageGenerator, error) {
+	return m.ResumePageGenerator(models.User{}, clients.Cursor{Client: m.Name(), NextURL: "page-0"})
+}
+
+func (m *MockClient) ResumePageGenerator(user models.User, cursor clients.Cursor) (clients.PageGenerator, error) {
+	return clients.NewURLPageGenerator(cursor, m.getPosts), nil
+}
+
+func (m *MockClient) Name() string {
+	if m.name == "" {
+		return mockName
+	}
+	return m.name
+}
+
+func (m *MockClient) Weight() float64 {
+	return 0
+}
+
+func (m *MockClient) Ping(ctx context.Context) error {
+	return m.pingErr
+}
+
+func (m *MockClient) getPosts(ctx context.Context, url string) clients.PostResponse {
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return clients.PostResponse{Err: ctx.Err()}
+	}
+	if m.err != nil {
+		return clients.PostResponse{Err: m.err}
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(url, "page-"))
+	if err != nil {
+		return clients.PostResponse{Err: err}
+	}
+
+	posts := make([]models.Post, mockPageSize)
+	for i := range posts {
+		id := fmt.Sprintf("%v-%v", n, i)
+		if m.name != "" {
+			id = m.name + "-" + id
+		}
+		posts[i] = models.Post{ID: id, Title: "Post " + id, Date: time.Now()}
+	}
+
+	nextURL := ""
+	if n+1 < mockPages {
+		nextURL = fmt.Sprintf("page-%v", n+1)
+	}
+
+	return clients.PostResponse{Posts: posts, NextURL: nextURL}
+}
+
+func init() {
+	clients.Register(mockName, func(conf clients.Config) (clients.Client, error) { return &MockClient{}, nil }, mockWeight)
+}
@@ -1,17 +1,57 @@
 package handlers
 
 import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/iced-mocha/core/storage"
 	"github.com/iced-mocha/shared/models"
 )
 
+// Credentials of the users returned by the mock driver, these must never appear in responses
+const (
+	mockPasswordHash       = "$2a$14$ljrYxvypCMju9hpgvEW.N.HaAgaK4fWHzJkXv/oEz7FS5HxBbWPTm"
+	mockRedditToken        = "reddit-bearer-token"
+	mockRedditRefreshToken = "reddit-refresh-token"
+	mockTwitterToken       = "twitter-auth-token"
+	mockTwitterSecret      = "twitter-secret"
+)
+
 type MockDriver struct {
+	// Returned by Ping when set
+	pingErr error
+	// The ranking strategy of every user, set by UpdateRankingStrategy
+	strategies map[string]string
+	// When each user saw each post, set by MarkSeen
+	seen map[string]map[string]time.Time
+	// The saved posts of every user by post id, set by SavePost
+	saved map[string]map[string]storage.SavedPost
+	// The mute filters of every user, set by InsertMuteFilter
+	filters map[string][]storage.MuteFilter
+	// The boosts of every user, set by InsertBoost
+	boosts map[string][]storage.Boost
+	// The rss groups of every user by name, set by UpdateRssFeeds and PutRssGroup
+	rss map[string]map[string]storage.RssGroup
 }
 
 func (m *MockDriver) InsertUser(user models.User) error { return nil }
 
 func (m *MockDriver) GetUser(username string) (models.User, bool, error) {
 	if username == "exists" || username == "userID" {
-		return models.User{Username: username, Password: "$2a$14$ljrYxvypCMju9hpgvEW.N.HaAgaK4fWHzJkXv/oEz7FS5HxBbWPTm"}, true, nil
+		return models.User{
+			ID:                 "id",
+			Username:           username,
+			Password:           mockPasswordHash,
+			RedditUsername:     "redditor",
+			RedditAuthToken:    mockRedditToken,
+			RedditRefreshToken: mockRedditRefreshToken,
+			TwitterUsername:    "tweeter",
+			TwitterAuthToken:   mockTwitterToken,
+			TwitterSecret:      mockTwitterSecret,
+			PostWeights:        models.Weights{Reddit: 20.0},
+			RssGroups:          map[string][]string{"news": []string{"http://example.com/rss"}},
+		}, true, nil
 	}
 	return models.User{}, false, nil
 }
@@ -31,9 +71,185 @@ func (m *MockDriver) UpdateFacebookAccount(userID, facebookUser, authToken strin
 }
 
 func (m *MockDriver) UpdateRssFeeds(username string, feeds map[string][]string) error {
+	groups := make(map[string]storage.RssGroup)
+	for name, f := range feeds {
+		group, exists, _ := m.GetRssGroup(username, name)
+		if !exists {
+			group.Weight = storage.DefaultRssGroupWeight
+		}
+		group.Feeds = f
+		groups[name] = group
+	}
+
+	if m.rss == nil {
+		m.rss = make(map[string]map[string]storage.RssGroup)
+	}
+	m.rss[username] = groups
+	return nil
+}
+
+func (m *MockDriver) GetRssGroup(username, name string) (storage.RssGroup, bool, error) {
+	group, exists := m.rss[username][name]
+	if !exists {
+		return storage.RssGroup{Name: name, Feeds: []string{}}, false, nil
+	}
+	return group, true, nil
+}
+
+func (m *MockDriver) PutRssGroup(username string, group storage.RssGroup) error {
+	if m.rss == nil {
+		m.rss = make(map[string]map[string]storage.RssGroup)
+	}
+	if m.rss[username] == nil {
+		m.rss[username] = make(map[string]storage.RssGroup)
+	}
+	m.rss[username][group.Name] = group
 	return nil
 }
 
+func (m *MockDriver) DeleteRssGroup(username, name string) (bool, error) {
+	_, exists := m.rss[username][name]
+	delete(m.rss[username], name)
+	return exists, nil
+}
+
 func (m *MockDriver) UpdateWeights(username string, weights models.Weights) bool { return true }
 
+func (m *MockDriver) GetWeights(username string) (map[string]float64, error) {
+	return map[string]float64{}, nil
+}
+
+func (m *MockDriver) UpdateSourceWeights(username string, weights map[string]float64) error {
+	return nil
+}
+
+func (m *MockDriver) GetRankingStrategy(username string) (string, error) {
+	return m.strategies[username], nil
+}
+
+func (m *MockDriver) UpdateRankingStrategy(username, strategy string) error {
+	if m.strategies == nil {
+		m.strategies = make(map[string]string)
+	}
+	m.strategies[username] = strategy
+	return nil
+}
+
+func (m *MockDriver) MarkSeen(username string, postIDs []string, at time.Time) error {
+	if m.seen == nil {
+		m.seen = make(map[string]map[string]time.Time)
+	}
+	if m.seen[username] == nil {
+		m.seen[username] = make(map[string]time.Time)
+	}
+	for _, id := range postIDs {
+		m.seen[username][id] = at
+	}
+	return nil
+}
+
+func (m *MockDriver) GetSeen(username string, since time.Time) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	for id, at := range m.seen[username] {
+		if !at.Before(since) {
+			seen[id] = true
+		}
+	}
+	return seen, nil
+}
+
+func (m *MockDriver) DeleteSeen(username string, before time.Time) error {
+	for id, at := range m.seen[username] {
+		if at.Before(before) {
+			delete(m.seen[username], id)
+		}
+	}
+	return nil
+}
+
+func (m *MockDriver) SavePost(username string, post models.Post, at time.Time) error {
+	if m.saved == nil {
+		m.saved = make(map[string]map[string]storage.SavedPost)
+	}
+	if m.saved[username] == nil {
+		m.saved[username] = make(map[string]storage.SavedPost)
+	}
+	if existing, ok := m.saved[username][post.ID]; ok {
+		at = existing.SavedAt
+	}
+	m.saved[username][post.ID] = storage.SavedPost{Post: post, SavedAt: at}
+	return nil
+}
+
+func (m *MockDriver) DeleteSavedPost(username, postID string) (bool, error) {
+	_, ok := m.saved[username][postID]
+	delete(m.saved[username], postID)
+	return ok, nil
+}
+
+func (m *MockDriver) GetSavedPosts(username string, after *storage.SavedCursor, limit int) ([]storage.SavedPost, error) {
+	posts := []storage.SavedPost{}
+	for _, p := range m.saved[username] {
+		if after == nil || p.SavedAt.Before(after.SavedAt) || (p.SavedAt.Equal(after.SavedAt) && p.ID > after.PostID) {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].SavedAt.Equal(posts[j].SavedAt) {
+			return posts[i].SavedAt.After(posts[j].SavedAt)
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (m *MockDriver) GetMuteFilters(username string) ([]storage.MuteFilter, error) {
+	return append([]storage.MuteFilter{}, m.filters[username]...), nil
+}
+
+func (m *MockDriver) InsertMuteFilter(username string, filter storage.MuteFilter) error {
+	if m.filters == nil {
+		m.filters = make(map[string][]storage.MuteFilter)
+	}
+	m.filters[username] = append(m.filters[username], filter)
+	return nil
+}
+
+func (m *MockDriver) DeleteMuteFilter(username, filterID string) (bool, error) {
+	for i, f := range m.filters[username] {
+		if f.ID == filterID {
+			m.filters[username] = append(m.filters[username][:i], m.filters[username][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockDriver) GetBoosts(username string) ([]storage.Boost, error) {
+	return append([]storage.Boost{}, m.boosts[username]...), nil
+}
+
+func (m *MockDriver) InsertBoost(username string, boost storage.Boost) error {
+	if m.boosts == nil {
+		m.boosts = make(map[string][]storage.Boost)
+	}
+	m.boosts[username] = append(m.boosts[username], boost)
+	return nil
+}
+
+func (m *MockDriver) DeleteBoost(username, boostID string) (bool, error) {
+	for i, b := range m.boosts[username] {
+		if b.ID == boostID {
+			m.boosts[username] = append(m.boosts[username][:i], m.boosts[username][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (m *MockDriver) UpdateOAuthToken(userID, token, expiry string) bool { return true }
+
+func (m *MockDriver) Ping(ctx context.Context) error { return m.pingErr }
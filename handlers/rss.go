@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/iced-mocha/core/clients/rss"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/storage"
+)
+
+const (
+	// Names of rss groups are stored in a VARCHAR(64)
+	maxRssGroupNameLength = 64
+	maxRssGroupFeeds      = 100
+	// Largest OPML document accepted by POST /v1/users/{userID}/rss/import
+	maxOPMLSize = 1 << 20
+)
+
+// Structure returned by us after receiving a call to POST /v1/users/{userID}/rss/import
+type RssImportResponse struct {
+	RssGroups map[string][]string `json:"rss-groups"`
+	// Feeds of the document that were left out as they are not valid feed urls
+	Skipped []string `json:"skipped"`
+}
+
+// Body of PUT and PATCH requests to /v1/users/{userID}/rss/{group}, fields that are left out are not changed
+type rssGroupRequest struct {
+	Feeds  *[]string `json:"feeds"`
+	Weight *float64  `json:"weight"`
+}
+
+// Checks that name can be used as the name of an rss group
+func validateRssGroupName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("rss groups must have a name")
+	} else if len(name) > maxRssGroupNameLength {
+		return fmt.Errorf("rss group names can be at most %v characters", maxRssGroupNameLength)
+	} else if name == "import" || name == "export" {
+		// GET /v1/users/{userID}/rss/export would never reach the group
+		return fmt.Errorf("%v cannot be used as the name of an rss group", name)
+	}
+	return nil
+}
+
+// Normalizes the feeds of an rss group, checking that there are not too many of them
+func normalizeRssGroupFeeds(feeds []string) ([]string, error) {
+	feeds, err := rss.NormalizeFeeds(feeds)
+	if err != nil {
+		return nil, err
+	} else if len(feeds) > maxRssGroupFeeds {
+		return nil, fmt.Errorf("rss groups can have at most %v feeds", maxRssGroupFeeds)
+	}
+	return feeds, nil
+}
+
+// GET /v1/users/{userID}/rss/{group}
+// Produces the feeds and weight of one of the user's rss groups
+func (h *CoreHandler) GetRssGroup(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	group, exists, err := h.Driver.GetRssGroup(u.Username, mux.Vars(r)["group"])
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to get rss group for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	res, err := json.Marshal(group)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+/* PUT /v1/users/{userID}/rss/{group}
+ * Expected body:
+ * 	{ "feeds": ["http://feeds.bbci.co.uk/news/rss.xml", ...], "weight": 13.0 }
+ * Creates the group or replaces its feeds. Without a weight an existing group keeps its weight and a new
+ * group gets the default weight. Responds with the group as stored.
+ */
+func (h *CoreHandler) PutRssGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateRssGroup(w, r, false)
+}
+
+/* PATCH /v1/users/{userID}/rss/{group}
+ * Expected body:
+ * 	{ "weight": 13.0 }
+ * Changes the feeds, the weight or both of an existing group. Responds with the group as stored.
+ */
+func (h *CoreHandler) PatchRssGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateRssGroup(w, r, true)
+}
+
+// Applies a PUT or PATCH request to an rss group, PATCH requests only apply to groups that exist
+func (h *CoreHandler) updateRssGroup(w http.ResponseWriter, r *http.Request, patch bool) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	name := mux.Vars(r)["group"]
+	if err := validateRssGroupName(name); err != nil {
+		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var req rssGroupRequest
+	if err := json.Unmarshal(contents, &req); err != nil {
+		logger.Warnf("Unable to marshal request body into rss group object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !patch && req.Feeds == nil {
+		http.Error(w, buildJSONError("Missing feeds of rss group"), http.StatusBadRequest)
+		return
+	}
+
+	group, exists, err := h.Driver.GetRssGroup(u.Username, name)
+	if err != nil {
+		logger.Errorf("Unable to get rss group for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if patch && !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if !exists {
+		group = storage.RssGroup{Name: name, Weight: storage.DefaultRssGroupWeight}
+	}
+
+	if req.Feeds != nil {
+		if group.Feeds, err = normalizeRssGroupFeeds(*req.Feeds); err != nil {
+			http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Weight != nil {
+		if *req.Weight < 0 {
+			http.Error(w, buildJSONError("Weights cannot be negative"), http.StatusBadRequest)
+			return
+		}
+		group.Weight = *req.Weight
+	}
+
+	if err := h.Driver.PutRssGroup(u.Username, group); err != nil {
+		logger.Errorf("Unable to store rss group for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(group)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !exists {
+		w.WriteHeader(http.StatusCreated)
+	}
+	w.Write(res)
+}
+
+// DELETE /v1/users/{userID}/rss/{group}
+// Removes one of the user's rss groups
+func (h *CoreHandler) DeleteRssGroup(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.Driver.DeleteRssGroup(u.Username, mux.Vars(r)["group"])
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to delete rss group for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+/* POST /v1/users/{userID}/rss/import?mode=<merge|replace>
+ * Expected body is an OPML document. Its categories become rss groups, feeds outside of any category are put
+ * in the uncategorized group. With mode=merge, the default, feeds are added to the user's groups, with
+ * mode=replace the groups of the document replace every group of the user. Feeds that are not valid feed urls
+ * are skipped. Responds with the user's groups after the import.
+ */
+func (h *CoreHandler) ImportRssFeeds(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = "merge"
+	} else if mode != "merge" && mode != "replace" {
+		http.Error(w, buildJSONError("mode must be merge or replace"), http.StatusBadRequest)
+		return
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(r.Body, maxOPMLSize+1))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if len(contents) > maxOPMLSize {
+		http.Error(w, buildJSONError(fmt.Sprintf("OPML documents can be at most %v bytes", maxOPMLSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	imported, err := rss.ParseOPML(bytes.NewReader(contents))
+	if err != nil {
+		http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	resp := RssImportResponse{RssGroups: make(map[string][]string), Skipped: []string{}}
+	if mode == "merge" {
+		for name, feeds := range u.RssGroups {
+			resp.RssGroups[name] = feeds
+		}
+	}
+
+	for name, feeds := range imported {
+		if err := validateRssGroupName(name); err != nil {
+			http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		for _, feed := range feeds {
+			if _, err := rss.NormalizeFeedURL(feed); err != nil {
+				resp.Skipped = append(resp.Skipped, feed)
+				continue
+			}
+			resp.RssGroups[name] = append(resp.RssGroups[name], feed)
+		}
+
+		if resp.RssGroups[name], err = normalizeRssGroupFeeds(resp.RssGroups[name]); err != nil {
+			http.Error(w, buildJSONError(fmt.Sprintf("Invalid rss group %v: %v", name, err)), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.Driver.UpdateRssFeeds(u.Username, resp.RssGroups); err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to import rss feeds for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// GET /v1/users/{userID}/rss/export
+// Produces an OPML document with a category for each of the user's rss groups
+func (h *CoreHandler) ExportRssFeeds(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var b bytes.Buffer
+	if err := rss.WriteOPML(&b, "RSS feeds of "+u.Username, u.RssGroups); err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to write OPML for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="feeds.opml"`)
+	w.Write(b.Bytes())
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
+)
+
+const (
+	// Number of saved posts returned by a single call to GET /v1/users/{userID}/saved unless a limit is given
+	defaultSavedPageSize = 20
+	maxSavedPageSize     = 100
+)
+
+var errInvalidSavedCursor = errors.New("Invalid cursor")
+
+// Structure returned by us after receiving a call to GET /v1/users/{userID}/saved
+type SavedPostsResponse struct {
+	Posts []storage.SavedPost `json:"posts"`
+	// Used to get the next saved posts, empty once there are no more
+	NextCursor string `json:"next_cursor"`
+}
+
+// The json form of a storage.SavedCursor handed out to clients
+type savedCursor struct {
+	SavedAt int64  `json:"t"`
+	PostID  string `json:"id"`
+}
+
+func encodeSavedCursor(post storage.SavedPost) (string, error) {
+	b, err := json.Marshal(savedCursor{post.SavedAt.UnixNano(), post.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeSavedCursor(cursor string) (*storage.SavedCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidSavedCursor
+	}
+
+	var c savedCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.PostID == "" {
+		return nil, errInvalidSavedCursor
+	}
+	return &storage.SavedCursor{SavedAt: time.Unix(0, c.SavedAt), PostID: c.PostID}, nil
+}
+
+// Gets the user named in the path of r provided r is authorized to act as them, otherwise responds with why not
+func (h *CoreHandler) authorizedUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	userID := mux.Vars(r)["userID"]
+
+	hasAuth, code := h.hasAuthorization(userID, r)
+	if !hasAuth {
+		w.WriteHeader(code)
+		return models.User{}, false
+	}
+
+	u, exists, err := h.Driver.GetUser(userID)
+	if !exists || err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to retrieve user %v from database: %v", userID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return models.User{}, false
+	}
+	return u, true
+}
+
+/* POST /v1/users/{userID}/saved
+ * Expected body is the post to save as returned by /v1/posts:
+ * 	{ "id": "...", "title": "...", "postLink": "...", ... }
+ */
+func (h *CoreHandler) SavePost(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var post models.Post
+	if err := json.Unmarshal(contents, &post); err != nil {
+		logger.Warnf("Unable to marshal request body into post object: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if post.ID == "" {
+		http.Error(w, buildJSONError("Post must have an id"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Driver.SavePost(u.Username, post, time.Now()); err != nil {
+		logger.Errorf("Unable to save post for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DELETE /v1/users/{userID}/saved?id=<post id>
+// Removes a post from the user's saved posts
+func (h *CoreHandler) DeleteSavedPost(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, buildJSONError("Missing id of post"), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Driver.DeleteSavedPost(u.Username, id)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to delete saved post for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /v1/users/{userID}/saved?limit=<n>&cursor=<cursor>
+// Produces the user's saved posts, most recently saved first. The next_cursor of the response
+// is passed as cursor to get the posts that follow.
+func (h *CoreHandler) GetSavedPosts(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultSavedPageSize
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxSavedPageSize {
+			http.Error(w, buildJSONError("limit must be between 1 and "+strconv.Itoa(maxSavedPageSize)), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var after *storage.SavedCursor
+	if c := r.FormValue("cursor"); c != "" {
+		var err error
+		if after, err = decodeSavedCursor(c); err != nil {
+			http.Error(w, buildJSONError(err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get one more post than asked for to find out whether there are any more
+	posts, err := h.Driver.GetSavedPosts(u.Username, after, limit+1)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Unable to get saved posts for user %v: %v", u.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := SavedPostsResponse{Posts: posts}
+	if len(posts) > limit {
+		resp.Posts = posts[:limit]
+		if resp.NextCursor, err = encodeSavedCursor(posts[limit-1]); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	res, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
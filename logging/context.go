@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/satori/go.uuid"
+)
+
+// The header request ids are read from and passed on to clients in
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// Produces a new random request id
+func NewRequestID() string {
+	return uuid.NewV4().String()
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// Produces the request id stored in ctx, or an empty string if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Produces a logger that annotates entries with the request id stored in ctx
+func FromContext(ctx context.Context) *Logger {
+	if id := RequestID(ctx); id != "" {
+		return root.With("request_id", id)
+	}
+	return root
+}
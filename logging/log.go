@@ -1,7 +1,64 @@
 package logging
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/iced-mocha/core/config"
+)
+
+// Matches the file:line prefix the standard logger adds with Lshortfile
+var shortFile = regexp.MustCompile(`^([\w.-]+\.go:\d+): `)
+
+// Writes lines from the standard logger as info entries so that existing log.Printf calls
+// produce the same structured output
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	caller := ""
+	if m := shortFile.FindStringSubmatch(msg); m != nil {
+		caller = m[1]
+		msg = msg[len(m[0]):]
+	}
+	root.write(InfoLevel, caller, msg)
+	return len(p), nil
+}
+
+// Reads the level and format of logs from the logging section of conf i.e
+//
+//	logging:
+//	  level: debug
+//	  format: text
+//
+// Both are optional, logs default to info level in JSON
+func Configure(conf config.Config) error {
+	if level, err := conf.GetString("logging.level"); err == nil {
+		l, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		SetLevel(l)
+	}
+
+	if format, err := conf.GetString("logging.format"); err == nil {
+		switch format {
+		case "json":
+			SetJSON(true)
+		case "text":
+			SetJSON(false)
+		default:
+			return fmt.Errorf("unknown log format %q", format)
+		}
+	}
+
+	return nil
+}
 
 func init() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// The time is added by our logger
+	log.SetFlags(log.Lshortfile)
+	log.SetOutput(stdWriter{})
 }
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", s)
+}
+
+// Where log entries are written and which of them are written, shared by every Logger
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	json  bool
+	now   func() time.Time
+}
+
+var out = &output{w: os.Stderr, level: InfoLevel, json: true, now: time.Now}
+
+// Sets where log entries are written
+func SetOutput(w io.Writer) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.w = w
+}
+
+// Sets the lowest level that is written, entries below it are dropped
+func SetLevel(level Level) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.level = level
+}
+
+// Sets whether entries are written as a JSON object per line or as plain text
+func SetJSON(enabled bool) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.json = enabled
+}
+
+// A Logger writes leveled log entries annotated with its fields. Loggers are immutable,
+// With produces a new logger with an additional field.
+type Logger struct {
+	fields map[string]interface{}
+}
+
+var root = &Logger{}
+
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fields := make(map[string]interface{}, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	return &Logger{fields: fields}
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(DebugLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(InfoLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(WarnLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(ErrorLevel, 1, fmt.Sprintf(format, args...))
+}
+
+// Writes msg at level, depth is the number of frames between the caller being logged and log
+func (l *Logger) log(level Level, depth int, msg string) {
+	caller := ""
+	if _, file, line, ok := runtime.Caller(depth + 1); ok {
+		caller = fmt.Sprintf("%v:%v", filepath.Base(file), line)
+	}
+	l.write(level, caller, msg)
+}
+
+func (l *Logger) write(level Level, caller, msg string) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+
+	if level < out.level {
+		return
+	}
+
+	entry := map[string]interface{}{}
+	for k, v := range l.fields {
+		entry[k] = redactField(k, v)
+	}
+	entry["time"] = out.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = Redact(msg)
+	if caller != "" {
+		entry["caller"] = caller
+	}
+
+	if out.json {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			line = []byte(fmt.Sprintf(`{"level":"error","msg":"unable to encode log entry: %v"}`, err))
+		}
+		out.w.Write(append(line, '\n'))
+		return
+	}
+
+	// Plain text puts the standard fields first and the rest in a stable order
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %-5v %v", entry["time"], strings.ToUpper(level.String()), entry["msg"])
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %v=%v", k, entry[k])
+	}
+	if caller != "" {
+		fmt.Fprintf(&b, " caller=%v", caller)
+	}
+	b.WriteByte('\n')
+	io.WriteString(out.w, b.String())
+}
+
+// Produces a logger with the given field
+func With(key string, value interface{}) *Logger {
+	return root.With(key, value)
+}
+
+func Debugf(format string, args ...interface{}) {
+	root.log(DebugLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func Infof(format string, args ...interface{}) {
+	root.log(InfoLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func Warnf(format string, args ...interface{}) {
+	root.log(WarnLevel, 1, fmt.Sprintf(format, args...))
+}
+
+func Errorf(format string, args ...interface{}) {
+	root.log(ErrorLevel, 1, fmt.Sprintf(format, args...))
+}
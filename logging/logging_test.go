@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/config/yaml"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	buf *bytes.Buffer
+}
+
+func (suite *LoggingTestSuite) SetupTest() {
+	suite.buf = &bytes.Buffer{}
+	SetOutput(suite.buf)
+	SetLevel(InfoLevel)
+	SetJSON(true)
+	out.now = func() time.Time { return time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC) }
+	log.SetOutput(stdWriter{})
+}
+
+func (suite *LoggingTestSuite) TearDownSuite() {
+	SetOutput(os.Stderr)
+	out.now = time.Now
+}
+
+// Decodes each line written to the log
+func (suite *LoggingTestSuite) entries() []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(suite.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		suite.Nil(json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (suite *LoggingTestSuite) TestJSON() {
+	With("user", "jgore").Warnf("Unable to get %v", "posts")
+
+	entries := suite.entries()
+	suite.Len(entries, 1)
+	suite.Equal("warn", entries[0]["level"])
+	suite.Equal("Unable to get posts", entries[0]["msg"])
+	suite.Equal("jgore", entries[0]["user"])
+	suite.Equal("2018-03-01T12:00:00Z", entries[0]["time"])
+	suite.Regexp(`^logging_test.go:\d+$`, entries[0]["caller"])
+}
+
+func (suite *LoggingTestSuite) TestLevels() {
+	Debugf("hidden")
+	Infof("shown")
+	SetLevel(ErrorLevel)
+	Warnf("hidden")
+	Errorf("shown")
+
+	entries := suite.entries()
+	suite.Len(entries, 2)
+	suite.Equal("info", entries[0]["level"])
+	suite.Equal("error", entries[1]["level"])
+
+	level, err := ParseLevel("DEBUG")
+	suite.Nil(err)
+	suite.Equal(DebugLevel, level)
+	_, err = ParseLevel("verbose")
+	suite.NotNil(err)
+}
+
+func (suite *LoggingTestSuite) TestText() {
+	SetJSON(false)
+	With("b", 2).With("a", 1).Infof("hello")
+	suite.Regexp(`^2018-03-01T12:00:00Z INFO  hello a=1 b=2 caller=logging_test.go:\d+\n$`, suite.buf.String())
+}
+
+func (suite *LoggingTestSuite) TestStandardLogger() {
+	log.Printf("from the %v logger", "standard")
+
+	entries := suite.entries()
+	suite.Len(entries, 1)
+	suite.Equal("info", entries[0]["level"])
+	suite.Equal("from the standard logger", entries[0]["msg"])
+	suite.Regexp(`^logging_test.go:\d+$`, entries[0]["caller"])
+}
+
+func (suite *LoggingTestSuite) TestRedact() {
+	log.Printf(`Received {"username": "jgore", "password": "hunter2", "reddit-auth-token": "abc"}`)
+	Infof("Fetching https://example.com/posts?count=20&access_token=abc&secret=def")
+	Infof("Sending Authorization: Bearer abc.def")
+	Infof("Fetching https://example.com/posts?authorization=abc&session_cookie=def&page=2")
+	With("RedditAuthToken", "abc").With("password", "hunter2").Infof("Linked account")
+
+	entries := suite.entries()
+	suite.Equal(`Received {"username": "jgore", "password": "[REDACTED]", "reddit-auth-token": "[REDACTED]"}`, entries[0]["msg"])
+	suite.Equal("Fetching https://example.com/posts?count=20&access_token=[REDACTED]&secret=[REDACTED]", entries[1]["msg"])
+	suite.Equal("Sending Authorization: Bearer [REDACTED]", entries[2]["msg"])
+	suite.Equal("Fetching https://example.com/posts?authorization=[REDACTED]&session_cookie=[REDACTED]&page=2", entries[3]["msg"])
+	suite.Equal("[REDACTED]", entries[4]["RedditAuthToken"])
+	suite.Equal("[REDACTED]", entries[4]["password"])
+	suite.NotContains(suite.buf.String(), "hunter2")
+}
+
+func (suite *LoggingTestSuite) TestRequestID() {
+	ctx := WithRequestID(context.Background(), "request-1")
+	suite.Equal("request-1", RequestID(ctx))
+	suite.Equal("", RequestID(context.Background()))
+
+	FromContext(ctx).Infof("with id")
+	FromContext(context.Background()).Infof("without id")
+
+	entries := suite.entries()
+	suite.Equal("request-1", entries[0]["request_id"])
+	suite.NotContains(entries[1], "request_id")
+	suite.NotEqual(NewRequestID(), NewRequestID())
+}
+
+func (suite *LoggingTestSuite) TestConfigure() {
+	f, err := ioutil.TempFile("", "logging")
+	suite.Nil(err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("---\nlogging:\n  level: warn\n  format: text\n")
+	suite.Nil(err)
+	suite.Nil(f.Close())
+
+	conf, err := yaml.New(f.Name())
+	suite.Nil(err)
+	suite.Nil(Configure(conf))
+	suite.Equal(WarnLevel, out.level)
+	suite.False(out.json)
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
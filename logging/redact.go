@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Names of fields that hold credentials, matched case insensitively against the end of a field name
+// so that i.e RedditAuthToken and refresh-token are both redacted
+var credentialFields = []string{"password", "token", "secret", "authorization", "cookie", "key"}
+
+var (
+	// Matches the name of a field that holds a credential
+	credentialName = `[\w-]*(?:` + strings.Join(credentialFields, "|") + `)`
+	// "password": "hunter2" in JSON
+	jsonCredential = regexp.MustCompile(`(?i)("` + credentialName + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// password=hunter2 in query strings and forms
+	queryCredential = regexp.MustCompile(`(?i)([?&\s]` + credentialName + `=)[^&\s]*`)
+	// Authorization: Bearer abc in headers
+	bearerCredential = regexp.MustCompile(`(?i)(bearer|basic)\s+[\w\-.~+/=]+`)
+)
+
+// Whether a field with the given name holds a credential
+func isCredential(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range credentialFields {
+		if strings.HasSuffix(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces credentials embedded in s, such as passwords in a JSON body or tokens in a url
+func Redact(s string) string {
+	s = jsonCredential.ReplaceAllString(s, `$1"`+redacted+`"`)
+	s = queryCredential.ReplaceAllString(s, "${1}"+redacted)
+	return bearerCredential.ReplaceAllString(s, "$1 "+redacted)
+}
+
+func redactField(name string, value interface{}) interface{} {
+	if isCredential(name) {
+		return redacted
+	}
+	if s, ok := value.(string); ok {
+		return Redact(s)
+	}
+	return value
+}
@@ -2,27 +2,82 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	_ "github.com/iced-mocha/core/clients/facebook"
+	_ "github.com/iced-mocha/core/clients/googlenews"
+	_ "github.com/iced-mocha/core/clients/hackernews"
+	_ "github.com/iced-mocha/core/clients/reddit"
+	_ "github.com/iced-mocha/core/clients/twitter"
 	"github.com/iced-mocha/core/config/yaml"
 	"github.com/iced-mocha/core/handlers"
-	_ "github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/metrics"
+	"github.com/iced-mocha/core/paging"
+	"github.com/iced-mocha/core/secrets"
 	"github.com/iced-mocha/core/server"
 	"github.com/iced-mocha/core/sessions"
 	_ "github.com/iced-mocha/core/sessions/memory"
+	_ "github.com/iced-mocha/core/sessions/redis"
 	"github.com/iced-mocha/core/storage/sql"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
 	certFile = "server.crt"
 	keyFile  = "server.key"
+
+	// Where metrics are served unless METRICS_ADDR is set
+	defaultMetricsAddr = ":9100"
 )
 
 func main() {
+	// Migrating the schema never reads or writes tokens so it does not need the keys
+	keyring, err := readKeyring(len(os.Args) < 2 || os.Args[1] != "migrate")
+	if err != nil {
+		log.Fatalf("Unable to read TOKEN_ENCRYPTION_KEYS: %v", err)
+	}
+
+	// Create our storage driver
+	driver, err := sql.New(sql.Config{
+		DatabasePath:   os.Getenv("DATABASE_PATH"),
+		DatabaseDriver: os.Getenv("DATABASE_TYPE"),
+		Keyring:        keyring,
+	})
+
+	if err != nil {
+		log.Fatalf("Unable to create driver: %v", err)
+	}
+
+	// core migrate up|down|status manages the schema without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(driver, os.Args[2:]); err != nil {
+			log.Fatalf("Unable to migrate database: %v", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before serving anything
+	if err := driver.MigrateUp(); err != nil {
+		log.Fatalf("Unable to migrate database: %v", err)
+	}
+
+	// core reencrypt encrypts every stored token with the primary key, i.e after rotating keys
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		n, err := driver.ReencryptTokens()
+		if err != nil {
+			log.Fatalf("Unable to re-encrypt tokens: %v", err)
+		}
+		log.Printf("Re-encrypted the tokens of %v users", n)
+		return
+	}
+
 	configFileName := "workspace.local.yml"
 
 	// Create our config object, look for a file called workspace.local.yml
@@ -37,36 +92,71 @@ func main() {
 		log.Fatalf("Unable to create configuration: %v", err)
 	}
 
-	// Create our storage driver
-	driver, err := sql.New(sql.Config{
-		DatabasePath:   os.Getenv("DATABASE_PATH"),
-		DatabaseDriver: os.Getenv("DATABASE_TYPE"),
-	})
+	if err := logging.Configure(config); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
 
+	// Sessions are stored in our database by default so they survive restarts
+	sessionProvider, err := driver.SessionProvider()
 	if err != nil {
-		log.Fatalf("Unable to create driver: %v", err)
+		log.Fatalf("Unable to create sql session provider: %v", err)
+	}
+	sessions.Register("sql", sessionProvider)
+
+	sessionProviderName := os.Getenv("SESSION_PROVIDER")
+	if sessionProviderName == "" {
+		sessionProviderName = "sql"
 	}
 
 	// Create our sessions manager
-	sm, err := sessions.NewManager("memory", "icedmochasecret", 3600*24*365)
+	sm, err := sessions.NewManager(sessionProviderName, "icedmochasecret", 3600*24*365)
 	if err != nil {
 		log.Fatalf("Unable to create session manager: %v", err)
 	}
 
+	// Start our session garbage collection
+	go sm.GC()
+
+	if err := metrics.RegisterActiveSessions(prometheus.DefaultRegisterer, sm.ActiveSessions); err != nil {
+		log.Fatalf("Unable to register session metrics: %v", err)
+	}
+
 	// Create our cache
 	c := cache.New(30*time.Minute, 45*time.Minute)
 
+	// Page tokens must be signed with the same key by every instance of core
+	pageTokenKey := []byte(os.Getenv("PAGE_TOKEN_SECRET"))
+	if len(pageTokenKey) == 0 {
+		log.Printf("PAGE_TOKEN_SECRET not set, generating a key. Page tokens will only be valid for this instance")
+		pageTokenKey, err = paging.NewKey()
+		if err != nil {
+			log.Fatalf("Unable to generate page token key: %v", err)
+		}
+	}
+
 	// Create our handler
-	handler, err := handlers.New(driver, sm, config, c)
+	handler, err := handlers.New(driver, sm, config, c, paging.NewSigner(pageTokenKey))
 	if err != nil {
-		log.Fatalf("Unable to create handler", err)
+		log.Fatalf("Unable to create handler: %v", err)
 	}
 
+	// Readiness is served from the results of probing dependencies in the background
+	go handler.MonitorHealth()
+
 	s, err := server.New(handler)
 	if err != nil {
 		log.Fatalf("error initializing server: %v", err)
 	}
 
+	// Metrics are served on their own listener so that they are not exposed along with the public api
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = defaultMetricsAddr
+	}
+	go func() {
+		log.Fatal(http.ListenAndServe(metricsAddr, server.NewMetrics()))
+	}()
+
 	srv := &http.Server{
 		Addr:      ":3000",
 		Handler:   s,
@@ -77,7 +167,60 @@ func main() {
 	log.Fatal(srv.ListenAndServeTLS("/usr/local/etc/ssl/certs/core.crt", "/usr/local/etc/ssl/private/core.key"))
 }
 
+// Reads the keyring tokens of linked accounts are encrypted with from TOKEN_ENCRYPTION_KEYS. When it is
+// required tokens are only stored unencrypted if ALLOW_PLAINTEXT_TOKENS=true, i.e for local development.
+func readKeyring(required bool) (*secrets.Keyring, error) {
+	if keys := os.Getenv("TOKEN_ENCRYPTION_KEYS"); keys != "" {
+		return secrets.ParseKeyring(keys)
+	}
+
+	if !required {
+		return nil, nil
+	}
+	if os.Getenv("ALLOW_PLAINTEXT_TOKENS") != "true" {
+		return nil, errors.New("not set, set ALLOW_PLAINTEXT_TOKENS=true to store tokens of linked accounts unencrypted")
+	}
+
+	log.Printf("TOKEN_ENCRYPTION_KEYS not set, tokens of linked accounts will be stored unencrypted")
+	return nil, nil
+}
+
 func checkExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return !os.IsNotExist(err)
 }
+
+// Runs the migrate subcommand, one of up, down or status
+func migrate(driver migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: core migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return driver.MigrateUp()
+	case "down":
+		return driver.MigrateDown()
+	case "status":
+		statuses, err := driver.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-50v %v\n", s.Version, s.Description, applied)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %v, expected up, down or status", args[0])
+}
+
+type migrator interface {
+	MigrateUp() error
+	MigrateDown() error
+	MigrationStatus() ([]sql.MigrationStatus, error)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Every metric is prefixed with core_
+const namespace = "core"
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	clientFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "client_fetch_duration_seconds",
+		Help:      "Time taken to fetch a page of posts from a client.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client"})
+
+	clientFetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_fetch_errors_total",
+		Help:      "Pages of posts that could not be fetched from a client.",
+	}, []string{"client"})
+
+	postsServed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_served_total",
+		Help:      "Posts served in feeds by the source they came from.",
+	}, []string{"source"})
+
+	pageTokenCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "page_token_cache_requests_total",
+		Help:      "Lookups of content providers for a page token by whether they were cached.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestDuration, clientFetchDuration, clientFetchErrors, postsServed, pageTokenCache)
+}
+
+// Serves every registered metric in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Records that a request to route took d and responded with code
+func ObserveRequest(route, method string, code int, d time.Duration) {
+	requestDuration.WithLabelValues(route, method, strconv.Itoa(code)).Observe(d.Seconds())
+}
+
+// Records a page fetch from client that took d and failed if err is not nil
+func ObserveClientFetch(client string, d time.Duration, err error) {
+	clientFetchDuration.WithLabelValues(client).Observe(d.Seconds())
+	if err != nil {
+		clientFetchErrors.WithLabelValues(client).Inc()
+	}
+}
+
+// Records that a post from source was served
+func PostServed(source string) {
+	postsServed.WithLabelValues(source).Inc()
+}
+
+// Records whether the content providers for a page token were found in the cache
+func PageTokenCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	pageTokenCache.WithLabelValues(result).Inc()
+}
+
+// Reports the result of count as the number of active sessions whenever metrics are collected from r
+func RegisterActiveSessions(r prometheus.Registerer, count func() (int, error)) error {
+	return r.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Sessions that have not expired.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			log.Printf("Unable to count active sessions: %v", err)
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
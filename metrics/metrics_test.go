@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+}
+
+// The metrics are registered once per process so each test starts them from zero
+func (suite *MetricsTestSuite) SetupTest() {
+	requestDuration.Reset()
+	clientFetchDuration.Reset()
+	clientFetchErrors.Reset()
+	postsServed.Reset()
+	pageTokenCache.Reset()
+}
+
+func (suite *MetricsTestSuite) TestClientFetch() {
+	ObserveClientFetch("test-client", time.Millisecond, nil)
+	ObserveClientFetch("test-client", time.Millisecond, errors.New("unavailable"))
+	suite.Equal(float64(1), testutil.ToFloat64(clientFetchErrors.WithLabelValues("test-client")))
+}
+
+func (suite *MetricsTestSuite) TestPageTokenCacheLookup() {
+	PageTokenCacheLookup(true)
+	PageTokenCacheLookup(false)
+	PageTokenCacheLookup(false)
+	suite.Equal(float64(1), testutil.ToFloat64(pageTokenCache.WithLabelValues("hit")))
+	suite.Equal(float64(2), testutil.ToFloat64(pageTokenCache.WithLabelValues("miss")))
+}
+
+func (suite *MetricsTestSuite) TestHandler() {
+	ObserveRequest("/v1/users/{userID}/weights", http.MethodPost, http.StatusOK, time.Millisecond)
+	PostServed("test-client")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	suite.Equal(http.StatusOK, w.Code)
+
+	body, err := ioutil.ReadAll(w.Body)
+	suite.Nil(err)
+	suite.Contains(string(body), `core_http_request_duration_seconds_count{code="200",method="POST",route="/v1/users/{userID}/weights"} 1`)
+	suite.Contains(string(body), `core_posts_served_total{source="test-client"} 1`)
+}
+
+func (suite *MetricsTestSuite) TestActiveSessions() {
+	registry := prometheus.NewRegistry()
+	sessions := 0
+	suite.Nil(RegisterActiveSessions(registry, func() (int, error) { return sessions, nil }))
+	sessions = 3
+
+	expected := `
+# HELP core_active_sessions Sessions that have not expired.
+# TYPE core_active_sessions gauge
+core_active_sessions 3
+`
+	suite.Nil(testutil.GatherAndCompare(registry, strings.NewReader(expected), "core_active_sessions"))
+
+	// Sessions are counted once per registry
+	suite.NotNil(RegisterActiveSessions(registry, func() (int, error) { return 0, nil }))
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
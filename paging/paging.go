@@ -0,0 +1,132 @@
+package paging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+)
+
+const (
+	keySize   = 32
+	nonceSize = 16
+)
+
+var (
+	ErrMalformedToken = errors.New("malformed page token")
+	ErrInvalidToken   = errors.New("page token signature does not match")
+	ErrExpiredToken   = errors.New("page token has expired")
+	ErrWrongOwner     = errors.New("page token belongs to another caller")
+)
+
+// Everything needed to resume a feed for the next call to /v1/posts
+type Token struct {
+	// Random value so that no two tokens are ever the same
+	Nonce string `json:"id"`
+	// Identifies who the token was issued to, see Signer.Owner
+	Owner string `json:"own"`
+	// Unix time after which the token can no longer be used
+	Expires int64            `json:"exp"`
+	Cursors []clients.Cursor `json:"cursors"`
+	// The ranking strategy the feed was started with so that every page is ranked the same way
+	Strategy string `json:"st,omitempty"`
+	// Seeds the randomness used to rank the next page so that it can be reproduced
+	Seed int64 `json:"sd,omitempty"`
+}
+
+// Signs and verifies page tokens so that any instance of core sharing the same key
+// can trust the cursors a client sends back to us
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Produces a random key suitable for signing tokens
+func NewKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Produces an opaque identifier for the caller described by parts. Tokens are readable by
+// whoever holds them so we never put session ids or other identifying values in them directly
+func (s *Signer) Owner(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString(s.sign(strings.Join(parts, "\x00"))[:16])
+}
+
+// Issues a token to owner that is valid for the given lifetime
+// The token is encoded as <payload>.<signature> where both parts are base64 url encoded
+func (s *Signer) Encode(t Token, owner string, lifetime time.Duration) (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	t.Nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	t.Owner = owner
+	t.Expires = time.Now().Add(lifetime).Unix()
+
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), nil
+}
+
+// Verifies the signature of the given token, that it has not expired and was issued to owner and decodes it
+func (s *Signer) Decode(token, owner string) (Token, error) {
+	var t Token
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return t, ErrMalformedToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return t, ErrMalformedToken
+	}
+
+	if !hmac.Equal(sig, s.sign(parts[0])) {
+		return t, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return t, ErrMalformedToken
+	}
+
+	if err := json.Unmarshal(payload, &t); err != nil {
+		return t, ErrMalformedToken
+	}
+
+	if time.Now().Unix() > t.Expires {
+		return t, ErrExpiredToken
+	}
+
+	if !hmac.Equal([]byte(t.Owner), []byte(owner)) {
+		return t, ErrWrongOwner
+	}
+
+	return t, nil
+}
+
+func (s *Signer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,107 @@
+package paging
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/stretchr/testify/suite"
+)
+
+const lifetime = time.Minute
+
+type PagingTestSuite struct {
+	suite.Suite
+	signer *Signer
+	owner  string
+}
+
+func (s *PagingTestSuite) SetupTest() {
+	key, err := NewKey()
+	s.Nil(err)
+	s.signer = NewSigner(key)
+	s.owner = s.signer.Owner("session", "sid")
+}
+
+func (s *PagingTestSuite) TestEncodeDecode() {
+	t := Token{Cursors: []clients.Cursor{
+		{Client: "hacker-news", NextURL: "http://hacker-news-client:4000/v1/posts?page=2", Offset: 3, SequenceLength: 1},
+		{Client: "rss", Group: "news", NextURL: "http://rss-client:9000/v1/posts?feeds=a,b"},
+	}}
+
+	token, err := s.signer.Encode(t, s.owner, lifetime)
+	s.Nil(err)
+
+	decoded, err := s.signer.Decode(token, s.owner)
+	s.Nil(err)
+	s.Equal(t.Cursors, decoded.Cursors)
+	s.Equal(s.owner, decoded.Owner)
+
+	// Encoding the same cursors twice should never produce the same token
+	other, err := s.signer.Encode(t, s.owner, lifetime)
+	s.Nil(err)
+	s.NotEqual(token, other)
+}
+
+func (s *PagingTestSuite) TestOwner() {
+	// Owners should be stable for the same caller and differ between callers
+	s.Equal(s.owner, s.signer.Owner("session", "sid"))
+	s.NotEqual(s.owner, s.signer.Owner("session", "other"))
+	s.NotEqual(s.signer.Owner("ab", "c"), s.signer.Owner("a", "bc"))
+
+	// The owner should not reveal the values it was built from
+	s.NotContains(s.owner, "sid")
+
+	// A token should only be usable by the caller it was issued to
+	token, err := s.signer.Encode(Token{}, s.owner, lifetime)
+	s.Nil(err)
+	_, err = s.signer.Decode(token, s.signer.Owner("session", "other"))
+	s.Equal(ErrWrongOwner, err)
+	_, err = s.signer.Decode(token, "")
+	s.Equal(ErrWrongOwner, err)
+}
+
+func (s *PagingTestSuite) TestDecodeExpired() {
+	token, err := s.signer.Encode(Token{}, s.owner, -time.Minute)
+	s.Nil(err)
+
+	_, err = s.signer.Decode(token, s.owner)
+	s.Equal(ErrExpiredToken, err)
+}
+
+func (s *PagingTestSuite) TestDecodeTampered() {
+	token, err := s.signer.Encode(Token{Cursors: []clients.Cursor{{Client: "reddit", NextURL: "https://reddit-client:3001/v1/posts"}}}, s.owner, lifetime)
+	s.Nil(err)
+
+	// Swapping in another payload without resigning should fail
+	other, err := s.signer.Encode(Token{Cursors: []clients.Cursor{{Client: "reddit", NextURL: "https://evil.com"}}}, s.owner, lifetime)
+	s.Nil(err)
+	forged := strings.Split(other, ".")[0] + "." + strings.Split(token, ".")[1]
+	_, err = s.signer.Decode(forged, s.owner)
+	s.Equal(ErrInvalidToken, err)
+
+	// A token signed with a different key should fail
+	key, err := NewKey()
+	s.Nil(err)
+	_, err = NewSigner(key).Decode(token, s.owner)
+	s.Equal(ErrInvalidToken, err)
+}
+
+func (s *PagingTestSuite) TestDecodeMalformed() {
+	// Tokens without a signature should fail
+	_, err := s.signer.Decode("abc", s.owner)
+	s.Equal(ErrMalformedToken, err)
+
+	// Old counter based tokens should fail
+	_, err = s.signer.Decode("1", s.owner)
+	s.Equal(ErrMalformedToken, err)
+
+	// Non base64 signatures should fail
+	_, err = s.signer.Decode("abc.!!!", s.owner)
+	s.Equal(ErrMalformedToken, err)
+}
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(PagingTestSuite))
+}
@@ -0,0 +1,106 @@
+package ranking
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/iced-mocha/shared/models"
+)
+
+// Multipliers of a boost must be within these bounds, multipliers below 1 lower the rank of matching posts
+const (
+	minBoostMultiplier = 0.1
+	maxBoostMultiplier = 10.0
+)
+
+// Types of boost
+const (
+	// Posts containing the word or phrase in their title or content
+	BoostKeyword = "keyword"
+	// Posts linking to the domain or any of its subdomains
+	BoostDomain = "domain"
+)
+
+// A rule multiplying the rank of the posts that match it
+type BoostRule struct {
+	Type       string
+	Value      string
+	Multiplier float64
+}
+
+// Produces how much the rank of a post is multiplied by, 1 leaves it unchanged
+type Booster func(models.Post) float64
+
+// Checks that a boost can be applied and puts its value into the form it is matched in
+func NormalizeBoost(b BoostRule) (BoostRule, error) {
+	b.Value = strings.TrimSpace(b.Value)
+
+	switch b.Type {
+	case BoostKeyword:
+	case BoostDomain:
+		b.Value = bareHost(b.Value)
+		if !strings.Contains(b.Value, ".") {
+			return b, fmt.Errorf("invalid domain %q", b.Value)
+		}
+	default:
+		return b, fmt.Errorf("unknown boost type %q", b.Type)
+	}
+
+	if b.Value == "" {
+		return b, fmt.Errorf("%v boost must have a value", b.Type)
+	} else if len(b.Value) > maxMuteValueLength {
+		return b, fmt.Errorf("boost values can be at most %v characters", maxMuteValueLength)
+	} else if b.Multiplier < minBoostMultiplier || b.Multiplier > maxBoostMultiplier {
+		return b, fmt.Errorf("boost multipliers must be between %v and %v", minBoostMultiplier, maxBoostMultiplier)
+	}
+	return b, nil
+}
+
+// Produces a booster multiplying the rank of posts by the multiplier of every boost they match, nil when
+// there are no boosts. Keywords are matched against the title and content of posts without regard to case.
+func NewBooster(rules []BoostRule) (Booster, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	type keywordBoost struct {
+		pattern    *regexp.Regexp
+		multiplier float64
+	}
+	var keywords []keywordBoost
+	domains := make(map[string]float64)
+	for _, b := range rules {
+		b, err := NormalizeBoost(b)
+		if err != nil {
+			return nil, err
+		}
+
+		switch b.Type {
+		case BoostKeyword:
+			keywords = append(keywords, keywordBoost{keywordPattern(b.Value), b.Multiplier})
+		case BoostDomain:
+			domains[b.Value] = b.Multiplier
+		}
+	}
+
+	return func(p models.Post) float64 {
+		multiplier := 1.0
+		if p.PostLink != "" && len(domains) > 0 {
+			host := bareHost(p.PostLink)
+			for d, m := range domains {
+				if inDomain(host, d) {
+					multiplier *= m
+				}
+			}
+		}
+
+		text := p.Title + "\n" + p.Content
+		for _, k := range keywords {
+			if k.pattern.MatchString(text) {
+				multiplier *= k.multiplier
+			}
+		}
+		return multiplier
+	}, nil
+}
@@ -0,0 +1,95 @@
+package ranking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type BoostTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (suite *BoostTestSuite) SetupTest() {
+	suite.now = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (suite *BoostTestSuite) TestNormalizeBoost() {
+	for _, c := range []struct{ in, out BoostRule }{
+		{BoostRule{Type: "keyword", Value: " golang  ", Multiplier: 2}, BoostRule{Type: "keyword", Value: "golang", Multiplier: 2}},
+		{BoostRule{Type: "domain", Value: "https://www.Example.com/news", Multiplier: 0.5}, BoostRule{Type: "domain", Value: "example.com", Multiplier: 0.5}},
+	} {
+		b, err := NormalizeBoost(c.in)
+		suite.Nil(err, c.in.Value)
+		suite.Equal(c.out, b)
+	}
+
+	for _, b := range []BoostRule{
+		{Type: "keyword", Value: " ", Multiplier: 2},
+		{Type: "keyword", Value: "golang"},
+		{Type: "keyword", Value: "golang", Multiplier: -1},
+		{Type: "keyword", Value: "golang", Multiplier: 100},
+		{Type: "domain", Value: "localhost", Multiplier: 2},
+		{Type: "subreddit", Value: "golang", Multiplier: 2},
+	} {
+		_, err := NormalizeBoost(b)
+		suite.NotNil(err, b.Value)
+	}
+}
+
+func (suite *BoostTestSuite) TestNewBooster() {
+	booster, err := NewBooster(nil)
+	suite.Nil(err)
+	suite.Nil(booster)
+
+	booster, err = NewBooster([]BoostRule{
+		{Type: BoostKeyword, Value: "go", Multiplier: 2},
+		{Type: BoostKeyword, Value: "rust", Multiplier: 3},
+		{Type: BoostDomain, Value: "example.com", Multiplier: 0.5},
+	})
+	suite.Nil(err)
+
+	for _, c := range []struct {
+		post       models.Post
+		multiplier float64
+	}{
+		{models.Post{Title: "Go 1.10 is released"}, 2},
+		{models.Post{Content: "Comparing go and Rust"}, 6},
+		{models.Post{Title: "Google announces a new phone"}, 1},
+		{models.Post{Title: "A story", PostLink: "https://news.example.com/story"}, 0.5},
+		{models.Post{Title: "A go story", PostLink: "https://www.example.com/story"}, 1},
+		{models.Post{Title: "A story", PostLink: "https://notexample.com/story"}, 1},
+	} {
+		suite.Equal(c.multiplier, booster(c.post), c.post.Title)
+	}
+}
+
+func (suite *BoostTestSuite) TestBoostedPostsRankHigher() {
+	booster, err := NewBooster([]BoostRule{{Type: BoostKeyword, Value: "golang", Multiplier: 1.5}})
+	suite.Nil(err)
+
+	// Both sources are equally weighted and publish at the same times so only boosts separate them
+	rank := func(strategy Strategy, booster Booster) []string {
+		a := newStaticProvider("a", 10, postsAt("a", suite.now, 2)...)
+		bPosts := postsAt("b", suite.now, 2)
+		bPosts[0].Title = "All about golang"
+		b := newStaticProvider("b", 10, bPosts...)
+		a.SetBooster(booster)
+		b.SetBooster(booster)
+		return postIDs(newExactRanker(strategy, suite.now).GetPosts(context.Background(), []*ContentProvider{a, b}, 1))
+	}
+
+	// Ties go to the first provider
+	for _, strategy := range []Strategy{blendStrategy{}, popularityStrategy{}} {
+		suite.Equal("a-1", rank(strategy, nil)[0])
+		suite.Equal("b-1", rank(strategy, booster)[0])
+	}
+}
+
+func TestBoostTestSuite(t *testing.T) {
+	suite.Run(t, new(BoostTestSuite))
+}
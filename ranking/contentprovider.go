@@ -1,43 +1,209 @@
 package ranking
 
 import (
+	"context"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/core/logging"
 	"github.com/iced-mocha/shared/models"
 )
 
 // a structure to keep track of the posts being read from a single content
 // provider for a single user. It records which page we are on, and contains
-// a function used to get the next page of data from the content provider
+// a generator used to get the next page of data from the content provider
 type ContentProvider struct {
 	Weight         float64
 	CurPage        []models.Post
-	NextPage       func() []models.Post
+	Generator      clients.PageGenerator
 	CurPost        *models.Post
-	nextPageChan   chan []models.Post
+	curCursor      clients.Cursor
+	nextPageChan   chan page
 	nextPost       int
 	sequenceLength int
+	// How far this provider is owed posts by the round robin strategy
+	credit float64
+	// Whether a page is currently being fetched into nextPageChan
+	fetching bool
+	// The error that ended the current page, if any
+	err error
+	// The request that created this provider, pages fetched ahead of time are logged against it
+	requestID string
+	// Posts that this reports true for are skipped
+	filter Filter
+	// Multiplies the rank of each post, may be nil
+	booster Booster
+	// Whether the provider stopped looking for a post that is not filtered out, see maxFilteredPages
+	paused bool
+	// The number of pages this provider has read
+	pagesRead int
 }
 
-func NewContentProvider(weight float64, nextPage func() []models.Post) *ContentProvider {
+// Reports whether a post should be left out of the feed
+type Filter func(models.Post) bool
+
+const (
+	// Pages fetched ahead of time are not tied to any request, they are bounded by this timeout instead
+	prefetchTimeout = 30 * time.Second
+
+	// How many pages a provider reads looking for a post that is not filtered out before giving up until
+	// the next page of posts is requested. Stops a heavy filter from holding up a page of posts.
+	maxFilteredPages = 3
+)
+
+// A page of posts along with the cursor that was used to fetch it
+type page struct {
+	posts  []models.Post
+	cursor clients.Cursor
+	err    error
+}
+
+// Creates a content provider and fetches its first page, the fetch is cancelled once ctx is done.
+// If the page cannot be fetched in time the provider has no posts and Err reports why.
+func NewContentProvider(ctx context.Context, weight float64, generator clients.PageGenerator) *ContentProvider {
 	c := &ContentProvider{
 		Weight:       weight,
-		NextPage:     nextPage,
-		nextPageChan: make(chan []models.Post, 1),
+		Generator:    generator,
+		nextPageChan: make(chan page, 1),
+		requestID:    logging.RequestID(ctx),
+	}
+	c.fetchPage(context.WithCancel(ctx))
+	c.NextPost(ctx)
+	return c
+}
+
+// Recreates a content provider from the cursor it produced, generator must have been
+// resumed from the same cursor so that its next page is the page the cursor was reading
+func ResumeContentProvider(ctx context.Context, weight float64, generator clients.PageGenerator, cursor clients.Cursor) *ContentProvider {
+	c := NewContentProvider(ctx, weight, generator)
+	for i := 0; i < cursor.Offset && c.CurPost != nil; i++ {
+		c.NextPost(ctx)
 	}
-	c.NextPost()
+	c.sequenceLength = cursor.SequenceLength
 	return c
 }
 
-func (c *ContentProvider) NextPost() {
+// Produces a cursor that can be used to resume this provider at its current post
+func (c *ContentProvider) Cursor() clients.Cursor {
+	cursor := c.curCursor
+	// nextPost has already moved past the current post
+	cursor.Offset = c.nextPost - 1
+	cursor.SequenceLength = c.sequenceLength
+	if c.paused {
+		// Every post left on the current page was filtered out or the next page was not ready in time so
+		// resume after them
+		cursor.Offset = c.nextPost
+	} else if c.CurPost == nil {
+		cursor.Offset = 0
+		if c.err == nil {
+			// This provider is exhausted so there is nothing left to resume
+			cursor.NextURL = ""
+		}
+		// Otherwise resuming retries the page that failed
+	}
+	return cursor
+}
+
+// The error that stopped this provider from producing posts, nil if it is exhausted or still has posts
+func (c *ContentProvider) Err() error {
+	return c.err
+}
+
+// Whether the provider has no current post because it gave up looking for a post that is not filtered
+// out or its next page was not ready in time. Unlike an exhausted provider it has posts left, which it
+// looks for again once resumed.
+func (c *ContentProvider) Paused() bool {
+	return c.paused
+}
+
+// Starts fetching the next page from the generator into nextPageChan, cancel is called once it is fetched
+func (c *ContentProvider) fetchPage(ctx context.Context, cancel context.CancelFunc) {
+	c.fetching = true
+	go func() {
+		defer cancel()
+		cursor := c.Generator.Cursor()
+		posts, err := c.Generator.NextPage(ctx)
+		c.nextPageChan <- page{posts, cursor, err}
+	}()
+}
+
+// Skips every post that filter reports true for, including the current post. Pages needed to skip the
+// current post are waited for until ctx is done.
+func (c *ContentProvider) SetFilter(ctx context.Context, filter Filter) {
+	c.filter = filter
+	if c.CurPost != nil && filter != nil && filter(*c.CurPost) {
+		c.NextPost(ctx)
+	}
+}
+
+// Multiplies the rank of every post of this provider by what booster produces for it
+func (c *ContentProvider) SetBooster(booster Booster) {
+	c.booster = booster
+}
+
+// How much the rank of the current post is multiplied by
+func (c *ContentProvider) boost() float64 {
+	if c.booster == nil || c.CurPost == nil {
+		return 1
+	}
+	return c.booster(*c.CurPost)
+}
+
+// Moves on to the next post that is not filtered out, fetching pages until one is found, the provider
+// is exhausted, maxFilteredPages pages have been read or ctx is done before the next page is ready
+func (c *ContentProvider) NextPost(ctx context.Context) {
+	c.paused = false
+	start := c.pagesRead
+	for {
+		c.advance(ctx)
+		if c.paused {
+			return
+		}
+		if c.CurPost == nil || c.filter == nil || !c.filter(*c.CurPost) {
+			return
+		}
+
+		if c.pagesRead-start >= maxFilteredPages && c.nextPost >= len(c.CurPage) {
+			c.CurPost = nil
+			c.paused = true
+			return
+		}
+	}
+}
+
+// Moves on to the next post, filtered or not. When the next page is not ready before ctx is done the
+// provider is paused with ctx's error so that the page is retried from its cursor rather than waited for.
+func (c *ContentProvider) advance(ctx context.Context) {
 	// preload the next page if we are getting close to needing it
-	if c.nextPost == len(c.CurPage)/2 {
-		go func() {
-			c.nextPageChan <- c.NextPage()
-		}()
+	if !c.fetching && c.nextPost >= len(c.CurPage)/2 {
+		prefetch := context.Background()
+		if c.requestID != "" {
+			prefetch = logging.WithRequestID(prefetch, c.requestID)
+		}
+		c.fetchPage(context.WithTimeout(prefetch, prefetchTimeout))
 	}
 
 	if c.nextPost >= len(c.CurPage) {
-		c.CurPage = <-c.nextPageChan
+		// A page that is already here is used even if ctx is done
+		var p page
+		select {
+		case p = <-c.nextPageChan:
+		default:
+			select {
+			case p = <-c.nextPageChan:
+			case <-ctx.Done():
+				logging.FromContext(ctx).Warnf("Timed out waiting for the next page of %v", c.curCursor.Source())
+				c.CurPost, c.err, c.paused = nil, ctx.Err(), true
+				return
+			}
+		}
+		c.fetching = false
+		c.CurPage, c.curCursor, c.err = p.posts, p.cursor, p.err
 		c.nextPost = 0
+		c.pagesRead++
+		if c.err != nil {
+			logging.With("request_id", c.requestID).Warnf("Unable to get next page of posts: %v", c.err)
+		}
 		if len(c.CurPage) == 0 {
 			c.CurPost = nil
 			return
@@ -0,0 +1,216 @@
+package ranking
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/iced-mocha/shared/models"
+)
+
+// A post along with every source it was found in. The same story is often surfaced by several
+// sources so duplicates are merged into the post that ranked highest.
+type Post struct {
+	models.Post
+	Sources []Source `json:"sources"`
+}
+
+// Where a post was found
+type Source struct {
+	// The client or rss group the post came from i.e reddit or rss/news
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	PostLink string `json:"postLink"`
+	Score    int    `json:"score"`
+}
+
+const (
+	// Titles whose words overlap by at least this much are considered the same story
+	titleSimilarityThreshold = 0.8
+	// Shorter titles are too generic to be compared, i.e "Ask HN"
+	minTitleWords = 4
+)
+
+// Query parameters that only track where a visitor came from and never change the page. Generic names
+// such as ref or share are left alone as some sites use them to pick what the page shows.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "igshid": true,
+	"ref_src": true, "ref_url": true, "referrer": true,
+	"cmpid": true, "mc_cid": true, "mc_eid": true, "ncid": true, "smid": true,
+	"outputtype": true, "spref": true,
+}
+
+// Host prefixes for mobile and amp versions of a site
+var variantPrefixes = []string{"www.", "m.", "mobile.", "amp."}
+
+// Produces a form of rawurl that is the same for every variant of the same page. Tracking parameters,
+// fragments and trailing slashes are dropped, and mobile, AMP and AMP cache urls resolve to the
+// page they are a copy of. Only amp.<host>, <article>/amp and AMP cache urls are taken to be AMP
+// copies. Urls that cannot be parsed are returned as is.
+func CanonicalURL(rawurl string) string {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+
+	host := strings.ToLower(u.Hostname())
+	p := u.EscapedPath()
+
+	// Google and the AMP project serve cached copies at i.e https://www.google.com/amp/s/example.com/story
+	if strings.HasSuffix(host, ".cdn.ampproject.org") || ((host == "google.com" || host == "www.google.com") && strings.HasPrefix(p, "/amp/")) {
+		// The cached page is addressed as [amp|c|v|i]/[s/]<host>/<path> where s means https
+		rest := strings.TrimPrefix(p, "/")
+		for _, prefix := range []string{"amp/", "c/", "v/", "i/"} {
+			rest = strings.TrimPrefix(rest, prefix)
+		}
+		rest = strings.TrimPrefix(rest, "s/")
+		if rest != "" {
+			if u.RawQuery != "" {
+				rest += "?" + u.RawQuery
+			}
+			return CanonicalURL("https://" + rest)
+		}
+	}
+
+	amp := false
+	for _, prefix := range variantPrefixes {
+		if strings.HasPrefix(host, prefix) {
+			amp = amp || prefix == "amp."
+			host = strings.TrimPrefix(host, prefix)
+		}
+	}
+
+	// AMP versions of articles usually live at <article>/amp
+	p = strings.TrimSuffix(p, "/")
+	if path.Base(p) == "amp" {
+		p = path.Dir(p)
+		amp = true
+	}
+	p = strings.TrimSuffix(p, "/")
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		// AMP copies are often flagged with ?amp=1, elsewhere amp may pick what the page shows
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] || (amp && lower == "amp") {
+			query.Del(key)
+		}
+	}
+
+	canonical := host + p
+	if len(query) > 0 {
+		// Encode sorts by key so the order of parameters does not matter
+		canonical += "?" + query.Encode()
+	}
+	return canonical
+}
+
+// Splits a title into its lowercase words, dropping punctuation and the name of the site that
+// published it i.e "Go 1.10 is released - The Go Blog" has the words go, 110, is and released
+func titleWords(title string) map[string]bool {
+	for _, sep := range []string{" - ", " | ", " — ", " – "} {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '.'
+	}) {
+		w = strings.Replace(w, ".", "", -1)
+		if w != "" {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// The fraction of words that two titles share (their jaccard index)
+func titleSimilarity(a, b map[string]bool) float64 {
+	if len(a) < minTitleWords || len(b) < minTitleWords {
+		return 0
+	}
+
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Tracks the posts on a page so that later copies of the same story can be merged into them
+type deduper struct {
+	posts []Post
+	urls  map[string]int
+	// Words of the title of each post in posts
+	titles []map[string]bool
+}
+
+func newDeduper() *deduper {
+	return &deduper{urls: make(map[string]int)}
+}
+
+// Adds post from source, merging it into an earlier post of the same story.
+// Reports whether post was new.
+func (d *deduper) add(post models.Post, source string) bool {
+	s := Source{Name: source, ID: post.ID, PostLink: post.PostLink, Score: post.Score}
+	canonical := ""
+	if post.PostLink != "" {
+		canonical = CanonicalURL(post.PostLink)
+	}
+	words := titleWords(post.Title)
+
+	if i, ok := d.duplicateOf(canonical, words); ok {
+		d.merge(i, post, s)
+		if canonical != "" {
+			d.urls[canonical] = i
+		}
+		return false
+	}
+
+	if canonical != "" {
+		d.urls[canonical] = len(d.posts)
+	}
+	d.posts = append(d.posts, Post{Post: post, Sources: []Source{s}})
+	d.titles = append(d.titles, words)
+	return true
+}
+
+// Finds the post that is the same story as a post with the given url and title words
+func (d *deduper) duplicateOf(canonical string, words map[string]bool) (int, bool) {
+	if i, ok := d.urls[canonical]; ok && canonical != "" {
+		return i, true
+	}
+
+	for i, title := range d.titles {
+		if titleSimilarity(words, title) >= titleSimilarityThreshold {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Records that post from source is a copy of the ith post, filling in anything the ith post is missing
+func (d *deduper) merge(i int, post models.Post, source Source) {
+	p := &d.posts[i]
+	for _, s := range p.Sources {
+		if s.Name == source.Name && s.ID == source.ID {
+			return
+		}
+	}
+	p.Sources = append(p.Sources, source)
+
+	if p.HeroImg == "" {
+		p.HeroImg = post.HeroImg
+	}
+	if p.Content == "" {
+		p.Content = post.Content
+	}
+	if p.Author == "" {
+		p.Author = post.Author
+	}
+}
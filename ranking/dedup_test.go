@@ -0,0 +1,123 @@
+package ranking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// A page generator that produces a single page of posts
+type staticGenerator struct {
+	cursor clients.Cursor
+	posts  []models.Post
+}
+
+func (g *staticGenerator) NextPage(ctx context.Context) ([]models.Post, error) {
+	posts := g.posts
+	g.posts = []models.Post{}
+	return posts, nil
+}
+
+func (g *staticGenerator) Cursor() clients.Cursor {
+	return g.cursor
+}
+
+func newStaticProvider(client string, weight float64, posts ...models.Post) *ContentProvider {
+	return NewContentProvider(context.Background(), weight, &staticGenerator{clients.Cursor{Client: client}, posts})
+}
+
+type DedupTestSuite struct {
+	suite.Suite
+}
+
+func (suite *DedupTestSuite) TestCanonicalURL() {
+	for rawurl, expected := range map[string]string{
+		"https://www.example.com/story/":                                "example.com/story",
+		"http://example.com/story#comments":                             "example.com/story",
+		"https://m.example.com/story?utm_source=hn&utm_medium=rss&id=3": "example.com/story?id=3",
+		"https://example.com/story?b=2&a=1&fbclid=abc":                  "example.com/story?a=1&b=2",
+		"https://example.com/story/amp":                                 "example.com/story",
+		"https://amp.example.com/story?amp=1":                           "example.com/story",
+		"https://www.google.com/amp/s/www.example.com/story/amp":        "example.com/story",
+		"https://example-com.cdn.ampproject.org/c/s/example.com/story":  "example.com/story",
+		"https://EXAMPLE.com/Story":                                     "example.com/Story",
+		// Only AMP copies of a page are rewritten and generic parameters can change what a page shows
+		"https://example.com/amp/story":                         "example.com/amp/story",
+		"https://example.com/story.amp":                         "example.com/story.amp",
+		"https://example.com/story?amp=1":                       "example.com/story?amp=1",
+		"https://github.com/golang/go/blob/file.go?ref=release": "github.com/golang/go/blob/file.go?ref=release",
+		"https://example.com/story?share=1&ref_src=twsrc":       "example.com/story?share=1",
+		"not a url": "not a url",
+	} {
+		suite.Equal(expected, CanonicalURL(rawurl), rawurl)
+	}
+}
+
+func (suite *DedupTestSuite) TestTitleSimilarity() {
+	similar := func(a, b string) bool {
+		return titleSimilarity(titleWords(a), titleWords(b)) >= titleSimilarityThreshold
+	}
+
+	suite.True(similar("Go 1.10 is released", "Go 1.10 Is Released! - The Go Blog"))
+	suite.True(similar("SpaceX launches the Falcon Heavy rocket", "SpaceX launches Falcon Heavy rocket | The Verge"))
+	suite.False(similar("SpaceX launches the Falcon Heavy rocket", "SpaceX delays the Falcon Heavy launch again"))
+	// Short titles are never considered similar
+	suite.False(similar("Ask HN", "Ask HN"))
+}
+
+func (suite *DedupTestSuite) TestGetPostsMergesDuplicates() {
+	now := time.Now()
+	hn := newStaticProvider("hacker-news", 100,
+		models.Post{ID: "hn-1", Date: now, Title: "Go 1.10 is released", PostLink: "https://blog.golang.org/go1.10?utm_source=hn", Score: 500},
+		models.Post{ID: "hn-2", Date: now.Add(-time.Hour), Title: "Show HN: A tiny text editor written in Go", PostLink: "https://example.com/editor"},
+	)
+	reddit := newStaticProvider("reddit", 10,
+		models.Post{ID: "r-1", Date: now, Title: "Go 1.10 Is Released - The Go Blog", PostLink: "https://reddit.com/r/golang/1", HeroImg: "https://example.com/gopher.png"},
+	)
+	rss := NewContentProvider(context.Background(), 1, &staticGenerator{clients.Cursor{Client: "rss", Group: "tech"}, []models.Post{
+		{ID: "rss-1", Date: now, Title: "Announcing Go 1.10", PostLink: "https://blog.golang.org/go1.10/"},
+	}})
+
+	posts := newTestRanker(blendStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{hn, reddit, rss}, 10)
+	suite.Len(posts, 2)
+
+	// Every copy of the release is merged into the highest ranked one
+	suite.Equal("hn-1", posts[0].ID)
+	suite.Equal([]Source{
+		{Name: "hacker-news", ID: "hn-1", PostLink: "https://blog.golang.org/go1.10?utm_source=hn", Score: 500},
+		{Name: "reddit", ID: "r-1", PostLink: "https://reddit.com/r/golang/1"},
+		{Name: "rss/tech", ID: "rss-1", PostLink: "https://blog.golang.org/go1.10/"},
+	}, posts[0].Sources)
+	suite.Equal("https://example.com/gopher.png", posts[0].HeroImg)
+
+	suite.Equal("hn-2", posts[1].ID)
+	suite.Len(posts[1].Sources, 1)
+}
+
+func (suite *DedupTestSuite) TestGetPostsFillsPage() {
+	now := time.Now()
+	a := newStaticProvider("a", 10,
+		models.Post{ID: "a-1", Date: now, PostLink: "https://example.com/1"},
+		models.Post{ID: "a-2", Date: now.Add(-2 * time.Hour), PostLink: "https://example.com/2"},
+	)
+	b := newStaticProvider("b", 10,
+		models.Post{ID: "b-1", Date: now.Add(-time.Hour), PostLink: "https://www.example.com/1/"},
+		models.Post{ID: "b-2", Date: now.Add(-3 * time.Hour), PostLink: "https://example.com/3"},
+	)
+
+	// Duplicates do not count towards the size of the page
+	posts := newTestRanker(blendStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b}, 3)
+	ids := []string{}
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	suite.Equal([]string{"a-1", "a-2", "b-2"}, ids)
+}
+
+func TestDedupTestSuite(t *testing.T) {
+	suite.Run(t, new(DedupTestSuite))
+}
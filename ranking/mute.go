@@ -0,0 +1,171 @@
+package ranking
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/iced-mocha/shared/models"
+)
+
+// The longest value a mute filter can have
+const maxMuteValueLength = 512
+
+// Types of mute rule
+const (
+	// Posts containing the word or phrase in their title or content
+	MuteKeyword = "keyword"
+	// Posts whose title or content matches the regular expression
+	MuteRegex = "regex"
+	// Posts linking to the domain or any of its subdomains
+	MuteDomain    = "domain"
+	MuteSubreddit = "subreddit"
+	MuteAuthor    = "author"
+)
+
+// A rule hiding the posts that match it
+type MuteRule struct {
+	Type  string
+	Value string
+}
+
+// Checks that a mute filter can be applied and puts its value into the form it is matched in,
+// i.e https://www.example.com/news becomes example.com and r/golang becomes golang
+func NormalizeMuteRule(f MuteRule) (MuteRule, error) {
+	f.Value = strings.TrimSpace(f.Value)
+
+	switch f.Type {
+	case MuteKeyword:
+	case MuteRegex:
+		if _, err := regexp.Compile(f.Value); err != nil {
+			return f, fmt.Errorf("invalid regex: %v", err)
+		}
+	case MuteDomain:
+		f.Value = bareHost(f.Value)
+		if !strings.Contains(f.Value, ".") {
+			return f, fmt.Errorf("invalid domain %q", f.Value)
+		}
+	case MuteSubreddit:
+		f.Value = strings.TrimPrefix(strings.TrimPrefix(f.Value, "/"), "r/")
+	case MuteAuthor:
+		f.Value = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(f.Value, "@"), "/"), "u/")
+	default:
+		return f, fmt.Errorf("unknown mute filter type %q", f.Type)
+	}
+
+	if f.Value == "" {
+		return f, fmt.Errorf("%v mute filter must have a value", f.Type)
+	} else if len(f.Value) > maxMuteValueLength {
+		return f, fmt.Errorf("mute filter values can be at most %v characters", maxMuteValueLength)
+	}
+	return f, nil
+}
+
+// Produces the host a domain or url refers to without any www. prefix
+func bareHost(value string) string {
+	value = strings.ToLower(value)
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// Keywords only match whole words so that i.e muting "go" does not hide posts about google
+func keywordPattern(keyword string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|\W)` + regexp.QuoteMeta(keyword) + `($|\W)`)
+}
+
+// Reports whether host is domain or one of its subdomains
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// Produces a filter that reports true for posts matching any of the given mute filters, nil when there
+// are none. Keywords and regular expressions are matched against the title and content of posts without
+// regard to case.
+func NewMuteFilter(rules []MuteRule) (Filter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var patterns []*regexp.Regexp
+	var domains []string
+	subreddits := make(map[string]bool)
+	authors := make(map[string]bool)
+	for _, f := range rules {
+		f, err := NormalizeMuteRule(f)
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.Type {
+		case MuteKeyword:
+			patterns = append(patterns, keywordPattern(f.Value))
+		case MuteRegex:
+			pattern, err := regexp.Compile("(?i)" + f.Value)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, pattern)
+		case MuteDomain:
+			domains = append(domains, f.Value)
+		case MuteSubreddit:
+			subreddits[strings.ToLower(f.Value)] = true
+		case MuteAuthor:
+			authors[strings.ToLower(f.Value)] = true
+		}
+	}
+
+	return func(p models.Post) bool {
+		if subreddits[strings.ToLower(p.Subreddit)] || authors[strings.ToLower(p.Author)] {
+			return true
+		}
+
+		if p.PostLink != "" && len(domains) > 0 {
+			host := bareHost(p.PostLink)
+			for _, d := range domains {
+				if inDomain(host, d) {
+					return true
+				}
+			}
+		}
+
+		text := p.Title + "\n" + p.Content
+		for _, pattern := range patterns {
+			if pattern.MatchString(text) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// Produces a filter that reports true for posts any of filters report true for, nil filters are ignored
+func AnyFilter(filters ...Filter) Filter {
+	var active []Filter
+	for _, f := range filters {
+		if f != nil {
+			active = append(active, f)
+		}
+	}
+
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+	return func(p models.Post) bool {
+		for _, f := range active {
+			if f(p) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,151 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type MuteTestSuite struct {
+	suite.Suite
+}
+
+func (suite *MuteTestSuite) TestNormalizeMuteRule() {
+	for _, c := range []struct{ in, out MuteRule }{
+		{MuteRule{Type: "keyword", Value: "  crypto "}, MuteRule{Type: "keyword", Value: "crypto"}},
+		{MuteRule{Type: "domain", Value: "https://www.Example.com/news"}, MuteRule{Type: "domain", Value: "example.com"}},
+		{MuteRule{Type: "domain", Value: "news.example.com"}, MuteRule{Type: "domain", Value: "news.example.com"}},
+		{MuteRule{Type: "subreddit", Value: "/r/golang"}, MuteRule{Type: "subreddit", Value: "golang"}},
+		{MuteRule{Type: "author", Value: "@jgore"}, MuteRule{Type: "author", Value: "jgore"}},
+		{MuteRule{Type: "regex", Value: `^Show HN`}, MuteRule{Type: "regex", Value: `^Show HN`}},
+	} {
+		f, err := NormalizeMuteRule(c.in)
+		suite.Nil(err, c.in.Value)
+		suite.Equal(c.out, f)
+	}
+
+	for _, f := range []MuteRule{
+		{Type: "keyword", Value: " "},
+		{Type: "regex", Value: "(unclosed"},
+		{Type: "domain", Value: "localhost"},
+		{Type: "subreddit", Value: "r/"},
+		{Type: "colour", Value: "blue"},
+	} {
+		_, err := NormalizeMuteRule(f)
+		suite.NotNil(err, f.Value)
+	}
+}
+
+func (suite *MuteTestSuite) TestNewMuteFilter() {
+	filter, err := NewMuteFilter(nil)
+	suite.Nil(err)
+	suite.Nil(filter)
+
+	filter, err = NewMuteFilter([]MuteRule{
+		{Type: MuteKeyword, Value: "go"},
+		{Type: MuteKeyword, Value: "C++"},
+		{Type: MuteRegex, Value: `^show hn:`},
+		{Type: MuteDomain, Value: "example.com"},
+		{Type: MuteSubreddit, Value: "r/Bitcoin"},
+		{Type: MuteAuthor, Value: "u/spammer"},
+	})
+	suite.Nil(err)
+
+	for _, p := range []models.Post{
+		{Title: "Go 1.10 is released"},
+		{Title: "Why I still write C++"},
+		{Content: "A post about go, among other things"},
+		{Title: "Show HN: A tiny editor"},
+		{Title: "A story", PostLink: "https://www.example.com/story"},
+		{Title: "A story", PostLink: "https://news.example.com/story"},
+		{Title: "To the moon", Subreddit: "bitcoin"},
+		{Title: "Buy now", Author: "Spammer"},
+	} {
+		suite.True(filter(p), p.Title)
+	}
+
+	for _, p := range []models.Post{
+		{Title: "Google announces a new phone"},
+		{Title: "A story about show hn: posts"},
+		{Title: "A story", PostLink: "https://notexample.com/story"},
+		{Title: "Ethereum", Subreddit: "ethereum"},
+		{Title: "Hello", Author: "someone"},
+	} {
+		suite.False(filter(p), p.Title)
+	}
+}
+
+func (suite *MuteTestSuite) TestAnyFilter() {
+	suite.Nil(AnyFilter(nil, nil))
+
+	a := func(p models.Post) bool { return p.ID == "a" }
+	b := func(p models.Post) bool { return p.ID == "b" }
+	filter := AnyFilter(a, nil, b)
+	suite.True(filter(models.Post{ID: "a"}))
+	suite.True(filter(models.Post{ID: "b"}))
+	suite.False(filter(models.Post{ID: "c"}))
+}
+
+// A page generator that produces pages of posts until it has produced pages pages
+type pagedGenerator struct {
+	client string
+	page   int
+	pages  int
+	now    time.Time
+}
+
+func (g *pagedGenerator) NextPage(ctx context.Context) ([]models.Post, error) {
+	if g.page >= g.pages {
+		return []models.Post{}, nil
+	}
+	g.page++
+	return hourlyPosts(fmt.Sprintf("%v%v", g.client, g.page), g.now, 5), nil
+}
+
+func (g *pagedGenerator) Cursor() clients.Cursor {
+	return clients.Cursor{Client: g.client, NextURL: strconv.Itoa(g.page)}
+}
+
+func (suite *MuteTestSuite) TestFilteredPages() {
+	now := time.Now()
+	// Every post of the first maxFilteredPages+1 pages is muted
+	muted := func(p models.Post) bool {
+		page, _ := strconv.Atoi(strings.TrimPrefix(strings.SplitN(p.ID, "-", 2)[0], "a"))
+		return page <= maxFilteredPages+1
+	}
+
+	a := NewContentProvider(context.Background(), 10, &pagedGenerator{client: "a", pages: 10, now: now})
+	b := newStaticProvider("b", 1, hourlyPosts("b", now, 3)...)
+	a.SetFilter(context.Background(), muted)
+
+	// A heavily filtered provider gives up for this page rather than reading every page, without being exhausted
+	suite.Nil(a.CurPost)
+	suite.True(a.Paused())
+	cursor := a.Cursor()
+	suite.Equal("a", cursor.Client)
+	suite.NotEmpty(cursor.NextURL)
+	suite.Equal(5, cursor.Offset)
+
+	// Other providers fill the page
+	posts := newTestRanker(blendStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b}, 10)
+	suite.Equal([]string{"b-1", "b-2", "b-3"}, postIDs(posts))
+
+	// The next page carries on from the page after the last one that was filtered out
+	page, err := strconv.Atoi(cursor.NextURL)
+	suite.Nil(err)
+	resumed := ResumeContentProvider(context.Background(), 10, &pagedGenerator{client: "a", pages: 10, now: now, page: page}, cursor)
+	resumed.SetFilter(context.Background(), muted)
+	suite.False(resumed.Paused())
+	suite.Equal(fmt.Sprintf("a%v-1", maxFilteredPages+2), resumed.CurPost.ID)
+}
+
+func TestMuteTestSuite(t *testing.T) {
+	suite.Run(t, new(MuteTestSuite))
+}
@@ -1,25 +1,42 @@
 package ranking
 
 import (
-	"github.com/iced-mocha/shared/models"
+	"context"
 	"math"
 	"math/rand"
 	"time"
+
+	"github.com/iced-mocha/core/metrics"
+	"github.com/iced-mocha/shared/models"
 )
 
-func getRank(p *models.Post, weight float64, sequenceLength int) float64 {
-	age := time.Since(p.Date)
+// Produces pages of posts ranked by a strategy. The clock and random numbers are injected so
+// that a page can be reproduced from the seed it was ranked with.
+type Ranker struct {
+	Strategy Strategy
+	Now      func() time.Time
+	Rand     *rand.Rand
+}
+
+func NewRanker(strategy Strategy, seed int64) *Ranker {
+	return &Ranker{Strategy: strategy, Now: time.Now, Rand: rand.New(rand.NewSource(seed))}
+}
+
+// Ranks a post by its age, the weight of its source and how much the user boosted it, penalizing posts that
+// would make a long run of posts from the same source
+func getRank(p *models.Post, weight, boost float64, sequenceLength int, now time.Time, rng *rand.Rand) float64 {
+	age := now.Sub(p.Date)
 	ageMultiplier := math.Pow((age + time.Hour*12).Minutes(), 1.2)
 	sequenceMultiplier := math.Pow(float64(1+sequenceLength), 0.2)
-	randomMultiplier := rand.Float64()/10 + 1.0
+	randomMultiplier := rng.Float64()/10 + 1.0
 	multiplier := ageMultiplier * sequenceMultiplier * randomMultiplier
 	if multiplier <= 0 {
 		return 0
 	}
-	return weight * (1.0 / multiplier)
+	return weight * boost * (1.0 / multiplier)
 }
 
-func getNextProviderIndex(providers []*ContentProvider) int {
+func getNextProviderIndex(providers []*ContentProvider, now time.Time, rng *rand.Rand) int {
 	topProvider := -1
 	topRank := -1.0
 
@@ -28,7 +45,7 @@ func getNextProviderIndex(providers []*ContentProvider) int {
 			continue
 		}
 
-		curRank := getRank(p.CurPost, p.Weight, p.sequenceLength)
+		curRank := getRank(p.CurPost, p.Weight, p.boost(), p.sequenceLength, now, rng)
 		if topProvider == -1 || curRank > topRank {
 			topProvider = i
 			topRank = curRank
@@ -44,28 +61,34 @@ func resetSequenceLengths(providers []*ContentProvider) {
 	}
 }
 
-// will modify the ContentProvider structs to contain the IDs of all viewed
-// posts and the current page being looked at
-func GetPosts(providers []*ContentProvider, count int) []models.Post {
-	posts := make([]models.Post, 0)
-
+// will modify the ContentProvider structs to move past every post taken from
+// them and the current page being looked at. The strategy of the ranker decides
+// the order in which posts are taken from the providers. Copies of the same story
+// from different sources are merged into the highest ranked copy and do not count
+// towards count. Providers whose next page is not ready before ctx is done are
+// paused and left out of the rest of the page.
+func (r *Ranker) GetPosts(ctx context.Context, providers []*ContentProvider, count int) []Post {
 	if len(providers) == 0 {
-		return posts
+		return []Post{}
 	}
 
-	for i := 0; i < count; i++ {
-		p := getNextProviderIndex(providers)
+	// Every post on a page is ranked at the same time so that the page can be ranked again from that time
+	now := r.Now()
+	d := newDeduper()
+	for len(d.posts) < count {
+		p := r.Strategy.Next(providers, now, r.Rand)
 		if p == -1 {
 			break
 		}
 		provider := providers[p]
-		posts = append(posts, *provider.CurPost)
-		provider.NextPost()
+		if d.add(*provider.CurPost, provider.curCursor.Source()) {
+			metrics.PostServed(provider.curCursor.Client)
+		}
+		provider.NextPost(ctx)
 		s := provider.sequenceLength + 1
 		resetSequenceLengths(providers)
 		provider.sequenceLength = s
 	}
 
-	return posts
-
+	return d.posts
 }
@@ -0,0 +1,209 @@
+package ranking
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/clients"
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// A source of random numbers that always produces 0 so that ranks have no jitter
+type zeroSource struct{}
+
+func (zeroSource) Int63() int64 { return 0 }
+func (zeroSource) Seed(int64)   {}
+
+// Produces a ranker whose clock is stopped at now
+func newTestRanker(strategy Strategy, now time.Time) *Ranker {
+	r := NewRanker(strategy, 1)
+	r.Now = func() time.Time { return now }
+	return r
+}
+
+// Produces a ranker whose clock is stopped at now and whose ranks have no jitter
+func newExactRanker(strategy Strategy, now time.Time) *Ranker {
+	r := newTestRanker(strategy, now)
+	r.Rand = rand.New(zeroSource{})
+	return r
+}
+
+// Produces count posts from client, all published at date
+func postsAt(client string, date time.Time, count int) []models.Post {
+	posts := hourlyPosts(client, date, count)
+	for i := range posts {
+		posts[i].Date = date
+	}
+	return posts
+}
+
+type RankingTestSuite struct {
+	suite.Suite
+	now   time.Time
+	exact *rand.Rand
+}
+
+func (suite *RankingTestSuite) SetupTest() {
+	suite.now = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+	suite.exact = rand.New(zeroSource{})
+}
+
+func (suite *RankingTestSuite) TestGetRankWeighting() {
+	p := &models.Post{Date: suite.now.Add(-time.Hour)}
+	rank := getRank(p, 10, 1, 0, suite.now, suite.exact)
+	suite.InDelta(2*rank, getRank(p, 20, 1, 0, suite.now, suite.exact), 1e-12)
+	suite.Equal(0.0, getRank(p, 0, 1, 0, suite.now, suite.exact))
+
+	// Older posts rank lower than newer posts of the same weight
+	old := &models.Post{Date: suite.now.Add(-24 * time.Hour)}
+	suite.True(getRank(old, 10, 1, 0, suite.now, suite.exact) < rank)
+
+	// Ranks only depend on the clock they are given
+	later := suite.now.Add(time.Hour)
+	suite.True(getRank(p, 10, 1, 0, later, suite.exact) < rank)
+}
+
+func (suite *RankingTestSuite) TestGetRankSequencePenalty() {
+	p := &models.Post{Date: suite.now}
+	rank := getRank(p, 10, 1, 0, suite.now, suite.exact)
+	suite.InDelta(rank/math.Pow(4, 0.2), getRank(p, 10, 1, 3, suite.now, suite.exact), 1e-12)
+}
+
+func (suite *RankingTestSuite) TestGetRankJitter() {
+	p := &models.Post{Date: suite.now}
+	exact := getRank(p, 10, 1, 0, suite.now, suite.exact)
+
+	// Jitter lowers ranks by at most a tenth and is the same for the same seed
+	a, b := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	for i := 0; i < 100; i++ {
+		rank := getRank(p, 10, 1, 0, suite.now, a)
+		suite.True(rank <= exact && rank >= exact/1.1)
+		suite.Equal(rank, getRank(p, 10, 1, 0, suite.now, b))
+	}
+}
+
+func (suite *RankingTestSuite) TestGetPostsWeighting() {
+	heavy := newStaticProvider("heavy", 100, hourlyPosts("heavy", suite.now, 3)...)
+	light := newStaticProvider("light", 1, hourlyPosts("light", suite.now, 3)...)
+
+	// The heavier source outranks the lighter one despite the sequence penalty until it runs out
+	posts := newExactRanker(blendStrategy{}, suite.now).GetPosts(context.Background(), []*ContentProvider{light, heavy}, 10)
+	suite.Equal([]string{"heavy-1", "heavy-2", "heavy-3", "light-1", "light-2", "light-3"}, postIDs(posts))
+}
+
+func (suite *RankingTestSuite) TestGetPostsSequencePenalty() {
+	// Without the sequence penalty every post of a would come first as they are all newer
+	a := newStaticProvider("a", 10, postsAt("a", suite.now, 3)...)
+	b := newStaticProvider("b", 10, postsAt("b", suite.now.Add(-time.Minute), 3)...)
+
+	posts := newExactRanker(blendStrategy{}, suite.now).GetPosts(context.Background(), []*ContentProvider{a, b}, 10)
+	suite.Equal([]string{"a-1", "b-1", "a-2", "b-2", "a-3", "b-3"}, postIDs(posts))
+}
+
+func (suite *RankingTestSuite) TestGetPostsExhaustion() {
+	ranker := newExactRanker(blendStrategy{}, suite.now)
+	suite.Equal([]Post{}, ranker.GetPosts(context.Background(), []*ContentProvider{}, 10))
+
+	a := newStaticProvider("a", 10, hourlyPosts("a", suite.now, 2)...)
+	empty := newStaticProvider("empty", 10)
+	unweighted := newStaticProvider("unweighted", 0, hourlyPosts("unweighted", suite.now, 2)...)
+	providers := []*ContentProvider{empty, unweighted, a}
+
+	// Pages end early once every weighted provider has run out
+	posts := ranker.GetPosts(context.Background(), providers, 10)
+	suite.Equal([]string{"a-1", "a-2"}, postIDs(posts))
+	suite.Nil(a.CurPost)
+	suite.Empty(ranker.GetPosts(context.Background(), providers, 10))
+}
+
+func (suite *RankingTestSuite) TestFilter() {
+	a := newStaticProvider("a", 10, hourlyPosts("a", suite.now, 4)...)
+	b := newStaticProvider("b", 10, hourlyPosts("b", suite.now, 2)...)
+	skip := map[string]bool{"a-1": true, "a-3": true, "b-1": true, "b-2": true}
+	filter := func(p models.Post) bool { return skip[p.ID] }
+
+	// The current post is skipped as soon as the filter is set
+	a.SetFilter(context.Background(), filter)
+	suite.Equal("a-2", a.CurPost.ID)
+	b.SetFilter(context.Background(), filter)
+	suite.Nil(b.CurPost)
+
+	posts := newExactRanker(blendStrategy{}, suite.now).GetPosts(context.Background(), []*ContentProvider{a, b}, 10)
+	suite.Equal([]string{"a-2", "a-4"}, postIDs(posts))
+}
+
+func (suite *RankingTestSuite) TestGetPostsSeed() {
+	// Weights close enough that jitter decides the order
+	rank := func(seed int64) []string {
+		providers := []*ContentProvider{}
+		for _, client := range []string{"a", "b", "c"} {
+			providers = append(providers, NewContentProvider(context.Background(), 10,
+				&staticGenerator{clients.Cursor{Client: client}, postsAt(client, suite.now, 10)}))
+		}
+		r := NewRanker(blendStrategy{}, seed)
+		r.Now = func() time.Time { return suite.now }
+		return postIDs(r.GetPosts(context.Background(), providers, 30))
+	}
+
+	first := rank(42)
+	suite.Len(first, 30)
+	suite.Equal(first, rank(42))
+}
+
+// A page generator whose pages after the first take delay to fetch
+type slowGenerator struct {
+	cursor clients.Cursor
+	pages  [][]models.Post
+	delay  time.Duration
+}
+
+func (g *slowGenerator) NextPage(ctx context.Context) ([]models.Post, error) {
+	if len(g.pages) == 0 {
+		return []models.Post{}, nil
+	}
+	posts := g.pages[0]
+	g.pages = g.pages[1:]
+	if g.cursor.NextURL != "" {
+		select {
+		case <-time.After(g.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	g.cursor.NextURL = "next"
+	return posts, nil
+}
+
+func (g *slowGenerator) Cursor() clients.Cursor {
+	return g.cursor
+}
+
+func (suite *RankingTestSuite) TestGetPostsSlowNextPage() {
+	generator := &slowGenerator{
+		cursor: clients.Cursor{Client: "slow"},
+		pages:  [][]models.Post{hourlyPosts("slow", suite.now, 2), hourlyPosts("slow-next", suite.now, 2)},
+		delay:  time.Second,
+	}
+	slow := NewContentProvider(context.Background(), 10, generator)
+
+	// The page is ranked without the next page rather than waiting for it past the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	posts := newExactRanker(blendStrategy{}, suite.now).GetPosts(ctx, []*ContentProvider{slow}, 10)
+	suite.True(time.Since(start) < generator.delay)
+	suite.Equal([]string{"slow-1", "slow-2"}, postIDs(posts))
+
+	// The provider resumes after the posts it has already ranked
+	suite.True(slow.Paused())
+	suite.Equal(context.DeadlineExceeded, slow.Err())
+	suite.Equal(2, slow.Cursor().Offset)
+}
+
+func TestRankingTestSuite(t *testing.T) {
+	suite.Run(t, new(RankingTestSuite))
+}
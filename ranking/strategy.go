@@ -0,0 +1,117 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// A Strategy decides which provider the next post on a page is taken from
+type Strategy interface {
+	// Produces the index of the provider whose current post comes next, or -1 when no provider has posts left.
+	// Providers without a current post or with a weight of 0 must never be picked. Strategies must take
+	// the time and any randomness from now and rng so that pages can be reproduced.
+	Next(providers []*ContentProvider, now time.Time, rng *rand.Rand) int
+}
+
+// The strategy used when a user has not picked one
+const DefaultStrategy = "blend"
+
+var strategies = map[string]Strategy{
+	"blend":         blendStrategy{},
+	"chronological": chronologicalStrategy{},
+	"round-robin":   roundRobinStrategy{},
+	"popularity":    popularityStrategy{},
+}
+
+// Produces the strategy with the given name, an empty name produces the default strategy
+func StrategyFor(name string) (Strategy, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	s, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown ranking strategy %q", name)
+	}
+	return s, nil
+}
+
+// Produces the names of every strategy in sorted order
+func Strategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func active(p *ContentProvider) bool {
+	return p.CurPost != nil && p.Weight != 0
+}
+
+// Picks the provider with the highest score, ties go to the provider that comes first
+func pickHighest(providers []*ContentProvider, score func(*ContentProvider) float64) int {
+	top := -1
+	topScore := 0.0
+	for i, p := range providers {
+		if !active(p) {
+			continue
+		}
+		if s := score(p); top == -1 || s > topScore {
+			top = i
+			topScore = s
+		}
+	}
+	return top
+}
+
+// Mixes newer posts from heavier sources in first while avoiding long runs from a single source
+type blendStrategy struct{}
+
+func (blendStrategy) Next(providers []*ContentProvider, now time.Time, rng *rand.Rand) int {
+	return getNextProviderIndex(providers, now, rng)
+}
+
+// Newest post first regardless of where it came from, its weight or boosts
+type chronologicalStrategy struct{}
+
+func (chronologicalStrategy) Next(providers []*ContentProvider, now time.Time, rng *rand.Rand) int {
+	return pickHighest(providers, func(p *ContentProvider) float64 {
+		return float64(p.CurPost.Date.UnixNano())
+	})
+}
+
+// Takes posts from each source in turn, a source with twice the weight of another gets twice as many
+// posts. Uses smooth weighted round robin so that the turns of heavier sources are spread out.
+type roundRobinStrategy struct{}
+
+func (roundRobinStrategy) Next(providers []*ContentProvider, now time.Time, rng *rand.Rand) int {
+	total := 0.0
+	for _, p := range providers {
+		if active(p) {
+			p.credit += math.Abs(p.Weight)
+			total += math.Abs(p.Weight)
+		}
+	}
+
+	i := pickHighest(providers, func(p *ContentProvider) float64 { return p.credit })
+	if i != -1 {
+		providers[i].credit -= total
+	}
+	return i
+}
+
+// Ranks posts by their score on the source they came from, decayed with age so that old but
+// popular posts eventually give way to newer ones
+type popularityStrategy struct{}
+
+func (popularityStrategy) Next(providers []*ContentProvider, now time.Time, rng *rand.Rand) int {
+	return pickHighest(providers, func(p *ContentProvider) float64 {
+		ageHours := math.Max(now.Sub(p.CurPost.Date).Hours(), 0)
+		score := math.Max(float64(p.CurPost.Score), 0)
+		return p.Weight * p.boost() * math.Log(2+score) / math.Pow(ageHours+2, 1.5)
+	})
+}
@@ -0,0 +1,100 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type StrategyTestSuite struct {
+	suite.Suite
+}
+
+// Produces count posts from client, each an hour older than the last
+func hourlyPosts(client string, start time.Time, count int) []models.Post {
+	posts := make([]models.Post, count)
+	for i := range posts {
+		id := fmt.Sprintf("%v-%v", client, i+1)
+		posts[i] = models.Post{ID: id, Title: id, Date: start.Add(-time.Duration(i) * time.Hour), PostLink: "https://example.com/" + id}
+	}
+	return posts
+}
+
+func postIDs(posts []Post) []string {
+	ids := []string{}
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func (suite *StrategyTestSuite) TestStrategyFor() {
+	for _, name := range Strategies() {
+		s, err := StrategyFor(name)
+		suite.Nil(err)
+		suite.NotNil(s)
+	}
+
+	s, err := StrategyFor("")
+	suite.Nil(err)
+	suite.Equal(blendStrategy{}, s)
+
+	_, err = StrategyFor("alphabetical")
+	suite.NotNil(err)
+
+	suite.Equal([]string{"blend", "chronological", "popularity", "round-robin"}, Strategies())
+}
+
+func (suite *StrategyTestSuite) TestChronological() {
+	now := time.Now()
+	// Weights are ignored, only the age of posts matters
+	a := newStaticProvider("a", 100, hourlyPosts("a", now.Add(-30*time.Minute), 2)...)
+	b := newStaticProvider("b", 1, hourlyPosts("b", now, 2)...)
+
+	posts := newTestRanker(chronologicalStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b}, 10)
+	suite.Equal([]string{"b-1", "a-1", "b-2", "a-2"}, postIDs(posts))
+}
+
+func (suite *StrategyTestSuite) TestRoundRobin() {
+	now := time.Now()
+	a := newStaticProvider("a", 20, hourlyPosts("a", now.Add(-24*time.Hour), 5)...)
+	b := newStaticProvider("b", 10, hourlyPosts("b", now, 5)...)
+
+	// a has twice the weight of b so it gets two turns for each of b's
+	posts := newTestRanker(roundRobinStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b}, 6)
+	suite.Equal([]string{"a-1", "b-1", "a-2", "a-3", "b-2", "a-4"}, postIDs(posts))
+}
+
+func (suite *StrategyTestSuite) TestRoundRobinExhausted() {
+	now := time.Now()
+	a := newStaticProvider("a", 10, hourlyPosts("a", now, 1)...)
+	b := newStaticProvider("b", 10, hourlyPosts("b", now, 3)...)
+	c := newStaticProvider("c", 0, hourlyPosts("c", now, 3)...)
+
+	// Sources without weight are left out and the rest carry on once a source runs out
+	posts := newTestRanker(roundRobinStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b, c}, 10)
+	suite.Equal([]string{"a-1", "b-1", "b-2", "b-3"}, postIDs(posts))
+}
+
+func (suite *StrategyTestSuite) TestPopularity() {
+	now := time.Now()
+	a := newStaticProvider("a", 1,
+		models.Post{ID: "a-1", Date: now, Score: 5, PostLink: "https://example.com/a-1"},
+		models.Post{ID: "a-2", Date: now.Add(-48 * time.Hour), Score: 100000, PostLink: "https://example.com/a-2"},
+	)
+	b := newStaticProvider("b", 1,
+		models.Post{ID: "b-1", Date: now, Score: 5000, PostLink: "https://example.com/b-1"},
+	)
+
+	// Popular posts come first, but old posts have to be much more popular to beat newer ones
+	posts := newTestRanker(popularityStrategy{}, now).GetPosts(context.Background(), []*ContentProvider{a, b}, 10)
+	suite.Equal([]string{"b-1", "a-1", "a-2"}, postIDs(posts))
+}
+
+func TestStrategyTestSuite(t *testing.T) {
+	suite.Run(t, new(StrategyTestSuite))
+}
@@ -28,18 +28,18 @@ installed_or_exit () {
 }
 
 # Verify the following are installed
-installed_or_exit sqlite3 'https://www.sqlite.org/quickstart.html'
+installed_or_exit go 'https://golang.org/doc/install'
 
 if [ -z ${GOPATH} ] 
  then 
 	# GOPATH is not set so create database in current directory
 	# In this case this script must be run inside of the /scripts directory
 	database_file="database.db"
-	init_database="initDB.sql"
+	core_dir=".."
  else 
 	# GOPATH is set
 	database_file="${GOPATH}/src/github.com/iced-mocha/core/database.db"
-	init_database="${GOPATH}/src/github.com/iced-mocha/core/scripts/initDB.sql"
+	core_dir="${GOPATH}/src/github.com/iced-mocha/core"
  fi
 
 # Now lets check if our database file exists so we can prevent accidently overwriting it
@@ -56,7 +56,6 @@ fi
 # Remove existing database file if it already exists
 rm ${database_file} 2> /dev/null
 
-# Create our table in the database
-sqlite3 ${database_file} < ${init_database}
-
-
+# Create our tables in the database, core owns the schema through its migrations
+database_file=$(cd $(dirname ${database_file}) && pwd)/$(basename ${database_file})
+(cd ${core_dir} && DATABASE_PATH=${database_file} go run main.go migrate up)
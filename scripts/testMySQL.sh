@@ -0,0 +1,19 @@
+#!/bin/bash
+# Runs the storage tests against mysql in a throwaway docker container, from anywhere in the repository:
+#	./scripts/testMySQL.sh
+
+container="core-mysql-test"
+port=3307
+
+docker run -d --rm --name ${container} -p ${port}:3306 \
+	-e MYSQL_ALLOW_EMPTY_PASSWORD=yes -e MYSQL_DATABASE=core_test mysql:5.7 > /dev/null || exit 1
+trap "docker stop ${container} > /dev/null" EXIT
+
+# Wait for mysql to accept queries, it restarts once while initializing so a ping is not enough
+until docker exec ${container} mysql -h 127.0.0.1 -e "SELECT 1" core_test > /dev/null 2>&1
+do
+	sleep 1
+done
+
+cd "$(dirname "$0")/.." || exit 1
+TEST_MYSQL_DSN="root@tcp(127.0.0.1:${port})/core_test" go test -v -run TestMySQLSuite ./storage/sql/
@@ -0,0 +1,228 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	keySize = 32
+
+	// Encrypted values are stored as enc:v2:<key id>:<wrapped data key>:<ciphertext>
+	prefix = "enc:v2:"
+	// Values encrypted before they were bound to additional data, they can be read until re-encrypted
+	legacyPrefix = "enc:v1:"
+)
+
+var (
+	ErrUnknownKey     = errors.New("value was encrypted with a key that is not in the keyring")
+	ErrMalformedValue = errors.New("malformed encrypted value")
+)
+
+// A Keyring encrypts values with envelope encryption. Every value is encrypted with its own random
+// data key, which is in turn encrypted (wrapped) with the primary key of the keyring. Older keys are
+// kept so values encrypted before a key rotation can still be decrypted until they are re-encrypted.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// Creates a keyring from the given keys, new values are encrypted with the primary key
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %v not in keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD)}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %v: %v", id, err)
+		}
+		k.keys[id] = aead
+	}
+
+	return k, nil
+}
+
+// Parses a keyring from a comma separated list of <id>:<base64 key> pairs where the first key is
+// the primary key, i.e "2:<new key>,1:<old key>"
+func ParseKeyring(s string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	var primary string
+	for i, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected <id>:<base64 key> but got %q", pair)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %v: %v", parts[0], err)
+		}
+
+		if i == 0 {
+			primary = parts[0]
+		}
+		keys[parts[0]] = key
+	}
+
+	return NewKeyring(primary, keys)
+}
+
+// Produces a random key suitable for a keyring
+func NewKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("keys must be %v bytes", keySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypts plaintext with a new data key bound to aad, which has to be given again to decrypt it so that
+// a value cannot be moved to another row or column. Empty values are left empty so that unlinked accounts
+// can still be told apart.
+func (k *Keyring) Encrypt(plaintext string, aad []byte) (string, error) {
+	return k.encrypt(prefix, plaintext, aad)
+}
+
+func (k *Keyring) encrypt(version, plaintext string, aad []byte) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey, err := NewKey()
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.keys[k.primary], dataKey, aad)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(aead, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+
+	return version + k.primary + ":" + encode(wrapped) + ":" + encode(ciphertext), nil
+}
+
+// Decrypts a value produced by Encrypt with the same aad. Values that were never encrypted (i.e stored
+// before encryption was enabled) are returned as is.
+func (k *Keyring) Decrypt(value string, aad []byte) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	if strings.HasPrefix(value, legacyPrefix) {
+		value, aad = strings.TrimPrefix(value, legacyPrefix), nil
+	} else {
+		value = strings.TrimPrefix(value, prefix)
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return "", ErrMalformedValue
+	}
+
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	wrapped, err := decode(parts[1])
+	if err != nil {
+		return "", ErrMalformedValue
+	}
+	ciphertext, err := decode(parts[2])
+	if err != nil {
+		return "", ErrMalformedValue
+	}
+
+	dataKey, err := open(kek, wrapped, aad)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(aead, ciphertext, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Whether value should be re-encrypted, either because it is plaintext, was encrypted with a key other
+// than the primary key or was encrypted before values were bound to additional data
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, prefix+k.primary+":")
+}
+
+// Whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix) || strings.HasPrefix(value, legacyPrefix)
+}
+
+// Encrypts plaintext bound to aad prepending the random nonce used
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrMalformedValue
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
+}
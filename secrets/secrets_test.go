@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SecretsTestSuite struct {
+	suite.Suite
+	oldKey []byte
+	newKey []byte
+	aad    []byte
+}
+
+func (suite *SecretsTestSuite) SetupSuite() {
+	var err error
+	suite.oldKey, err = NewKey()
+	suite.Nil(err)
+	suite.newKey, err = NewKey()
+	suite.Nil(err)
+	suite.aad = []byte("jgore\x00RedditAuthToken")
+}
+
+func (suite *SecretsTestSuite) TestEncryptDecrypt() {
+	k, err := NewKeyring("1", map[string][]byte{"1": suite.oldKey})
+	suite.Nil(err)
+
+	encrypted, err := k.Encrypt("token", suite.aad)
+	suite.Nil(err)
+	suite.True(IsEncrypted(encrypted))
+	suite.NotContains(encrypted, "token")
+
+	// Every value gets its own data key so the same plaintext never encrypts the same way twice
+	again, err := k.Encrypt("token", suite.aad)
+	suite.Nil(err)
+	suite.NotEqual(encrypted, again)
+
+	decrypted, err := k.Decrypt(encrypted, suite.aad)
+	suite.Nil(err)
+	suite.Equal("token", decrypted)
+
+	// Empty values stay empty and plaintext values are passed through
+	encrypted, err = k.Encrypt("", suite.aad)
+	suite.Nil(err)
+	suite.Equal("", encrypted)
+	decrypted, err = k.Decrypt("plaintext", suite.aad)
+	suite.Nil(err)
+	suite.Equal("plaintext", decrypted)
+}
+
+func (suite *SecretsTestSuite) TestRotation() {
+	old, err := NewKeyring("1", map[string][]byte{"1": suite.oldKey})
+	suite.Nil(err)
+	encrypted, err := old.Encrypt("token", suite.aad)
+	suite.Nil(err)
+
+	rotated, err := NewKeyring("2", map[string][]byte{"1": suite.oldKey, "2": suite.newKey})
+	suite.Nil(err)
+	suite.True(rotated.NeedsRotation(encrypted))
+	suite.True(rotated.NeedsRotation("plaintext"))
+	suite.False(rotated.NeedsRotation(""))
+
+	decrypted, err := rotated.Decrypt(encrypted, suite.aad)
+	suite.Nil(err)
+	suite.Equal("token", decrypted)
+
+	reencrypted, err := rotated.Encrypt(decrypted, suite.aad)
+	suite.Nil(err)
+	suite.False(rotated.NeedsRotation(reencrypted))
+
+	// Once the old key is removed only re-encrypted values can be read
+	current, err := NewKeyring("2", map[string][]byte{"2": suite.newKey})
+	suite.Nil(err)
+	_, err = current.Decrypt(encrypted, suite.aad)
+	suite.Equal(ErrUnknownKey, err)
+	decrypted, err = current.Decrypt(reencrypted, suite.aad)
+	suite.Nil(err)
+	suite.Equal("token", decrypted)
+}
+
+func (suite *SecretsTestSuite) TestTampered() {
+	k, err := NewKeyring("1", map[string][]byte{"1": suite.oldKey})
+	suite.Nil(err)
+	encrypted, err := k.Encrypt("token", suite.aad)
+	suite.Nil(err)
+
+	parts := strings.Split(encrypted, ":")
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	suite.Nil(err)
+	ciphertext[len(ciphertext)-1] ^= 1
+	parts[len(parts)-1] = base64.RawStdEncoding.EncodeToString(ciphertext)
+
+	_, err = k.Decrypt(strings.Join(parts, ":"), suite.aad)
+	suite.NotNil(err)
+	_, err = k.Decrypt(prefix+"1:garbage", suite.aad)
+	suite.Equal(ErrMalformedValue, err)
+}
+
+func (suite *SecretsTestSuite) TestAdditionalData() {
+	k, err := NewKeyring("1", map[string][]byte{"1": suite.oldKey})
+	suite.Nil(err)
+	encrypted, err := k.Encrypt("token", suite.aad)
+	suite.Nil(err)
+
+	// Values cannot be read as if they belonged to another user or column
+	_, err = k.Decrypt(encrypted, []byte("someone\x00RedditAuthToken"))
+	suite.NotNil(err)
+	_, err = k.Decrypt(encrypted, nil)
+	suite.NotNil(err)
+
+	// Values encrypted before they were bound to additional data are still read, until re-encrypted
+	legacy, err := k.encrypt(legacyPrefix, "token", nil)
+	suite.Nil(err)
+	suite.True(IsEncrypted(legacy))
+	suite.True(k.NeedsRotation(legacy))
+	decrypted, err := k.Decrypt(legacy, suite.aad)
+	suite.Nil(err)
+	suite.Equal("token", decrypted)
+}
+
+func (suite *SecretsTestSuite) TestParseKeyring() {
+	k, err := ParseKeyring("2:" + base64.StdEncoding.EncodeToString(suite.newKey) + ", 1:" + base64.StdEncoding.EncodeToString(suite.oldKey))
+	suite.Nil(err)
+	encrypted, err := k.Encrypt("token", suite.aad)
+	suite.Nil(err)
+	suite.True(strings.HasPrefix(encrypted, prefix+"2:"))
+
+	_, err = ParseKeyring("nokey")
+	suite.NotNil(err)
+	_, err = ParseKeyring("1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	suite.NotNil(err)
+}
+
+func TestSecretsTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretsTestSuite))
+}
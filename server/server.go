@@ -2,11 +2,17 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/iced-mocha/core/handlers"
+	"github.com/iced-mocha/core/logging"
+	"github.com/iced-mocha/core/metrics"
 )
 
+// Request ids sent by callers that are longer than this are replaced rather than logged
+const maxRequestIDLength = 128
+
 type Server struct {
 	Router *mux.Router
 }
@@ -21,31 +27,107 @@ func New(api handlers.CoreAPI) (*Server, error) {
 	// Uses session id in cookie to retrieve user id
 	s.Router.HandleFunc("/v1/users", api.GetUser).Methods("GET")
 	s.Router.HandleFunc("/v1/users/{userID}/weights", api.UpdateWeights).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/strategy", api.UpdateRankingStrategy).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/seen", api.MarkSeen).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/saved", api.SavePost).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/saved", api.DeleteSavedPost).Methods("DELETE")
+	s.Router.HandleFunc("/v1/users/{userID}/saved", api.GetSavedPosts).Methods("GET")
+	s.Router.HandleFunc("/v1/users/{userID}/filters", api.GetMuteFilters).Methods("GET")
+	s.Router.HandleFunc("/v1/users/{userID}/filters", api.InsertMuteFilter).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/filters/{filterID}", api.DeleteMuteFilter).Methods("DELETE")
+	s.Router.HandleFunc("/v1/users/{userID}/boosts", api.GetBoosts).Methods("GET")
+	s.Router.HandleFunc("/v1/users/{userID}/boosts", api.InsertBoost).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/boosts/{boostID}", api.DeleteBoost).Methods("DELETE")
 	s.Router.HandleFunc("/v1/login", api.Login).Methods("POST")
 	s.Router.HandleFunc("/v1/logout", api.Logout).Methods("POST")
 	s.Router.HandleFunc("/v1/loggedin", api.IsLoggedIn).Methods("GET")
 	s.Router.HandleFunc("/v1/users/{userID}/rss", api.UpdateRssFeeds).Methods("POST")
+	// Registered before /v1/users/{userID}/rss/{group} so they are matched first
+	s.Router.HandleFunc("/v1/users/{userID}/rss/import", api.ImportRssFeeds).Methods("POST")
+	s.Router.HandleFunc("/v1/users/{userID}/rss/export", api.ExportRssFeeds).Methods("GET")
+	s.Router.HandleFunc("/v1/users/{userID}/rss/{group}", api.GetRssGroup).Methods("GET")
+	s.Router.HandleFunc("/v1/users/{userID}/rss/{group}", api.PutRssGroup).Methods("PUT")
+	s.Router.HandleFunc("/v1/users/{userID}/rss/{group}", api.PatchRssGroup).Methods("PATCH")
+	s.Router.HandleFunc("/v1/users/{userID}/rss/{group}", api.DeleteRssGroup).Methods("DELETE")
 	s.Router.HandleFunc("/v1/users/{userID}/accounts/{type}", api.DeleteLinkedAccount).Methods("DELETE")
 
 	s.Router.HandleFunc("/v1/users/{userID}/authorize/twitter", api.TwitterAuth).Methods("GET")
 	s.Router.HandleFunc("/v1/users/{userID}/authorize/reddit", api.RedditAuth).Methods("GET")
 	s.Router.HandleFunc("/v1/users/{userID}/authorize/{type}", api.UpdateAccountAuth).Methods("POST")
 
+	s.Router.HandleFunc("/v1/health/clients", api.GetClientHealth).Methods("GET")
+	s.Router.HandleFunc("/healthz", api.Healthz).Methods("GET")
+	s.Router.HandleFunc("/readyz", api.Readyz).Methods("GET")
+
 	return s, nil
 }
 
+// Produces the router of the internal listener metrics are scraped from, which is kept off the public api
+func NewMetrics() http.Handler {
+	router := mux.NewRouter()
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	return router
+}
+
 func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	if origin := req.Header.Get("Origin"); origin != "" {
 		rw.Header().Set("Access-Control-Allow-Origin", origin)
 		rw.Header().Set("Access-Control-Allow-Credentials", "true")
 		rw.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 		rw.Header().Set("Access-Control-Allow-Headers",
-			"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+			"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, "+logging.RequestIDHeader)
+		rw.Header().Set("Access-Control-Expose-Headers", logging.RequestIDHeader)
 	}
 	// Stop here if its Preflighted OPTIONS request
 	if req.Method == "OPTIONS" {
 		return
 	}
+
+	// Every request gets an id that is logged with it and passed on to clients
+	id := req.Header.Get(logging.RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = logging.NewRequestID()
+	}
+	rw.Header().Set(logging.RequestIDHeader, id)
+	req = req.WithContext(logging.WithRequestID(req.Context(), id))
+
 	// Lets Gorilla work
-	s.Router.ServeHTTP(rw, req)
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: rw, code: http.StatusOK}
+	s.Router.ServeHTTP(recorder, req)
+
+	route := s.route(req)
+	metrics.ObserveRequest(route, req.Method, recorder.code, time.Since(start))
+	logging.FromContext(req.Context()).
+		With("method", req.Method).
+		With("route", route).
+		With("status", recorder.code).
+		With("duration_ms", float64(time.Since(start))/float64(time.Millisecond)).
+		Infof("Served %v %v", req.Method, req.URL.Path)
+}
+
+// Produces the path template of the route matching req so that requests for different
+// users are recorded under the same route
+func (s *Server) route(req *http.Request) string {
+	var match mux.RouteMatch
+	if !s.Router.Match(req, &match) || match.Route == nil {
+		return "unmatched"
+	}
+
+	route, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return route
+}
+
+// Records the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
 }
@@ -13,6 +13,9 @@ import (
 	"time"
 )
 
+// How often expired sessions are removed from providers that sweep them
+const gcInterval = 10 * time.Minute
+
 // Manager for managing all sessions within the application
 type Manager struct {
 	cookieName  string     // Name of the cookie we are storing in the users cookies -- essentially the key of where to look for a session id
@@ -43,15 +46,42 @@ func NewManager(providerName, cookieName string, maxlifetime int64) (*Manager, e
 	if !ok {
 		return nil, fmt.Errorf("requested unknown provider. %v not registered", providerName)
 	}
+
+	if p, ok := provider.(ExpiringProvider); ok {
+		p.SetMaxLifetime(maxlifetime)
+	} else if p, ok := provider.(SweepingProvider); ok {
+		p.SetSessionLifetime(maxlifetime)
+	}
+
 	return &Manager{provider: provider, cookieName: cookieName, maxlifetime: maxlifetime}, nil
 }
 
-// Session garbage collection -- TODO: If we choose to persist cookies to files this will have to be changed
+// Session garbage collection, removes expired sessions from the provider every maxlifetime seconds, or
+// every gcInterval for providers that sweep them when that is sooner.
+// This is a no-op for providers that expire sessions themselves
 func (manager *Manager) GC() {
+	if _, ok := manager.provider.(ExpiringProvider); ok {
+		return
+	}
+
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
 	manager.provider.SessionGC(manager.maxlifetime)
-	time.AfterFunc(time.Duration(manager.maxlifetime), func() { manager.GC() })
+
+	interval := time.Duration(manager.maxlifetime) * time.Second
+	if _, ok := manager.provider.(SweepingProvider); ok && gcInterval < interval {
+		interval = gcInterval
+	}
+	time.AfterFunc(interval, func() { manager.GC() })
+}
+
+// Produces the number of sessions that have not expired
+func (manager *Manager) ActiveSessions() (int, error) {
+	p, ok := manager.provider.(CountingProvider)
+	if !ok {
+		return 0, errors.New("session provider cannot count sessions")
+	}
+	return p.SessionCount(manager.maxlifetime)
 }
 
 func (manager *Manager) GetSession(r *http.Request) (Session, error) {
@@ -91,7 +121,7 @@ func (manager *Manager) SessionStart(w http.ResponseWriter, r *http.Request) (se
 		// TODO: HttpOnly should probably be true
 		cookie := http.Cookie{Name: manager.cookieName, Value: url.QueryEscape(sid), Path: "/", HttpOnly: true, MaxAge: int(manager.maxlifetime)}
 		http.SetCookie(w, &cookie)
-		log.Printf("Writing cookie for session id: %v", sid)
+		log.Printf("Writing session cookie")
 		return
 	}
 
@@ -114,7 +144,10 @@ func (manager *Manager) SessionDestroy(w http.ResponseWriter, r *http.Request) {
 		log.Printf("destroying session")
 		manager.lock.Lock()
 		defer manager.lock.Unlock()
-		manager.provider.SessionDestroy(cookie.Value)
+		// Session ids are escaped when written to the cookie
+		if sid, err := url.QueryUnescape(cookie.Value); err == nil {
+			manager.provider.SessionDestroy(sid)
+		}
 		// Overwrite the current cookie with an expired one
 		cookie := http.Cookie{Name: manager.cookieName, Path: "/", HttpOnly: true, Expires: time.Unix(0, 0), MaxAge: -1}
 		http.SetCookie(w, &cookie)
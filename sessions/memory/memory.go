@@ -134,6 +134,20 @@ func (p *MemoryProvider) SessionUpdate(sid string) error {
 	return fmt.Errorf("No such session: %v", sid)
 }
 
+// Counts the sessions accessed within the last maxlifetime seconds
+func (p *MemoryProvider) SessionCount(maxlifetime int64) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	count := 0
+	for _, element := range p.sessions {
+		if element.Value.(*Session).timeAccessed.Unix()+maxlifetime >= time.Now().Unix() {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func init() {
 	provider.sessions = make(map[string]*list.Element, 0)
 	// Register our memory storage provider
@@ -7,3 +7,25 @@ type Provider interface {
 	SessionGC(maxLifetime int64)
 	SessionUpdate(id string) error
 }
+
+// Providers whose backing store expires sessions natively implement ExpiringProvider.
+// They are told how long sessions live up front and SessionGC is never called.
+type ExpiringProvider interface {
+	Provider
+	SetMaxLifetime(maxLifetime int64)
+}
+
+// Providers whose backing store keeps sessions until they are swept implement SweepingProvider.
+// They are told how long sessions live up front so that expired sessions are never produced, which
+// lets SessionGC sweep them every gcInterval rather than as soon as they expire.
+type SweepingProvider interface {
+	Provider
+	SetSessionLifetime(maxLifetime int64)
+}
+
+// Providers that can count their sessions implement CountingProvider, sessions that have not
+// been accessed within maxLifetime seconds are not counted.
+type CountingProvider interface {
+	Provider
+	SessionCount(maxLifetime int64) (int, error)
+}
@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/iced-mocha/core/sessions"
+)
+
+const (
+	defaultAddress = "localhost:6379"
+	keyPrefix      = "session:"
+
+	// Used until the session manager tells us otherwise
+	defaultMaxLifetime = 3600
+)
+
+var provider = New(address())
+
+// Implementation of the Session interface -- no conflict due to being in different packages
+type Session struct {
+	sid      string
+	lock     sync.Mutex
+	values   map[string]interface{}
+	provider *RedisProvider
+}
+
+// Set a value in the session store
+func (s *Session) Set(key string, value interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values[key] = value
+	return s.provider.save(s)
+}
+
+func (s *Session) Get(key string) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (s *Session) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values, key)
+	return s.provider.save(s)
+}
+
+// Get the id of a given session
+func (s *Session) SessionID() string {
+	return s.sid
+}
+
+// Stores sessions in anything that speaks the redis protocol. Sessions are stored with a TTL
+// of maxlifetime which is refreshed on access, so redis takes care of garbage collection
+type RedisProvider struct {
+	pool        *redigo.Pool
+	maxlifetime int64
+}
+
+// Creates a provider for the redis server at address, connections are only made once sessions are used
+func New(address string) *RedisProvider {
+	pool := &redigo.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", address)
+		},
+	}
+
+	return &RedisProvider{pool: pool, maxlifetime: defaultMaxLifetime}
+}
+
+// Sets how long sessions live for after they were last accessed
+func (p *RedisProvider) SetMaxLifetime(maxlifetime int64) {
+	p.maxlifetime = maxlifetime
+}
+
+// Creates a new session and stores it in redis
+func (p *RedisProvider) SessionInit(sid string) (sessions.Session, error) {
+	s := &Session{sid: sid, values: make(map[string]interface{}), provider: p}
+	data, err := sessions.EncodeValues(s.values)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", key(sid), data, "EX", p.maxlifetime); err != nil {
+		log.Printf("Unable to insert session: %v", err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Produces the session associated with the given session id
+func (p *RedisProvider) SessionRead(sid string) (sessions.Session, error) {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	data, err := redigo.Bytes(conn.Do("GET", key(sid)))
+	if err == redigo.ErrNil {
+		return nil, fmt.Errorf("no such session %v", sid)
+	} else if err != nil {
+		return nil, err
+	}
+
+	values, err := sessions.DecodeValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading a session counts as accessing it
+	if _, err := conn.Do("EXPIRE", key(sid), p.maxlifetime); err != nil {
+		return nil, err
+	}
+
+	return &Session{sid: sid, values: values, provider: p}, nil
+}
+
+// Destroys the session associated with the given id
+func (p *RedisProvider) SessionDestroy(sid string) error {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", key(sid))
+	return err
+}
+
+// Redis expires sessions for us so there is nothing to collect
+func (p *RedisProvider) SessionGC(maxlifetime int64) {}
+
+// Resets the time to live of the session to maxlifetime
+func (p *RedisProvider) SessionUpdate(sid string) error {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	updated, err := redigo.Bool(conn.Do("EXPIRE", key(sid), p.maxlifetime))
+	if err != nil {
+		return err
+	} else if !updated {
+		return fmt.Errorf("No such session: %v", sid)
+	}
+	return nil
+}
+
+// Counts the sessions in redis, expired sessions have already been removed by redis
+func (p *RedisProvider) SessionCount(maxlifetime int64) (int, error) {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	count := 0
+	cursor := 0
+	for {
+		reply, err := redigo.Values(conn.Do("SCAN", cursor, "MATCH", keyPrefix+"*", "COUNT", 1000))
+		if err != nil {
+			return 0, err
+		}
+
+		var keys []string
+		if _, err := redigo.Scan(reply, &cursor, &keys); err != nil {
+			return 0, err
+		}
+		count += len(keys)
+
+		if cursor == 0 {
+			return count, nil
+		}
+	}
+}
+
+// Writes the values of the session to redis, sessions that have been destroyed or expired are not recreated
+func (p *RedisProvider) save(s *Session) error {
+	data, err := sessions.EncodeValues(s.values)
+	if err != nil {
+		return err
+	}
+
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	reply, err := conn.Do("SET", key(s.sid), data, "EX", p.maxlifetime, "XX")
+	if err != nil {
+		log.Printf("Unable to save session: %v", err)
+		return err
+	} else if reply == nil {
+		return fmt.Errorf("No such session: %v", s.sid)
+	}
+	return nil
+}
+
+func key(sid string) string {
+	return keyPrefix + sid
+}
+
+// The address of the redis server, configurable through REDIS_ADDR
+func address() string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultAddress
+}
+
+func init() {
+	// Register our redis storage provider
+	sessions.Register("redis", provider)
+}
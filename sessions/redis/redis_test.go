@@ -0,0 +1,145 @@
+package redis
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/iced-mocha/core/sessions"
+	"github.com/stretchr/testify/suite"
+)
+
+const maxlifetime = 60
+
+type RedisTestSuite struct {
+	suite.Suite
+	server   *miniredis.Miniredis
+	provider *RedisProvider
+}
+
+func (suite *RedisTestSuite) SetupSuite() {
+	log.SetOutput(ioutil.Discard)
+}
+
+func (suite *RedisTestSuite) SetupTest() {
+	var err error
+	suite.server, err = miniredis.Run()
+	suite.Nil(err)
+
+	suite.provider = New(suite.server.Addr())
+	suite.provider.SetMaxLifetime(maxlifetime)
+}
+
+func (suite *RedisTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *RedisTestSuite) TestSessionPersists() {
+	s, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+	suite.Equal("sid", s.SessionID())
+	suite.Nil(s.Set("username", "jack"))
+
+	// Another provider talking to the same server (i.e another instance of core) should see the session
+	s, err = New(suite.server.Addr()).SessionRead("sid")
+	suite.Nil(err)
+	suite.Equal("jack", s.Get("username"))
+	suite.Nil(s.Get("missing"))
+
+	// Deleted values should stay deleted
+	suite.Nil(s.Delete("username"))
+	s, err = suite.provider.SessionRead("sid")
+	suite.Nil(err)
+	suite.Nil(s.Get("username"))
+}
+
+func (suite *RedisTestSuite) TestSessionExpires() {
+	_, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+	suite.Equal(maxlifetime*time.Second, suite.server.TTL(key("sid")))
+
+	// Accessing the session should reset its time to live
+	suite.server.FastForward(30 * time.Second)
+	s, err := suite.provider.SessionRead("sid")
+	suite.Nil(err)
+	suite.Equal(maxlifetime*time.Second, suite.server.TTL(key("sid")))
+
+	suite.server.FastForward(30 * time.Second)
+	suite.Nil(suite.provider.SessionUpdate("sid"))
+	suite.Equal(maxlifetime*time.Second, suite.server.TTL(key("sid")))
+
+	// Once it expires it should be gone without ever running GC
+	suite.server.FastForward((maxlifetime + 1) * time.Second)
+	_, err = suite.provider.SessionRead("sid")
+	suite.NotNil(err)
+	suite.NotNil(suite.provider.SessionUpdate("sid"))
+
+	// Setting values on an expired session should not bring it back
+	suite.NotNil(s.Set("username", "jack"))
+	suite.False(suite.server.Exists(key("sid")))
+}
+
+func (suite *RedisTestSuite) TestSessionDestroy() {
+	_, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+
+	suite.Nil(suite.provider.SessionDestroy("sid"))
+	_, err = suite.provider.SessionRead("sid")
+	suite.NotNil(err)
+
+	// Destroying a session that doesnt exist is not an error
+	suite.Nil(suite.provider.SessionDestroy("sid"))
+}
+
+func (suite *RedisTestSuite) TestSessionCount() {
+	for _, sid := range []string{"a", "b", "c"} {
+		_, err := suite.provider.SessionInit(sid)
+		suite.Nil(err)
+	}
+	// Keys that are not sessions should not be counted
+	suite.Nil(suite.server.Set("other", "value"))
+
+	count, err := suite.provider.SessionCount(maxlifetime)
+	suite.Nil(err)
+	suite.Equal(3, count)
+
+	suite.server.FastForward((maxlifetime + 1) * time.Second)
+	count, err = suite.provider.SessionCount(maxlifetime)
+	suite.Nil(err)
+	suite.Equal(0, count)
+}
+
+func (suite *RedisTestSuite) TestManager() {
+	suite.Nil(sessions.Register("redis-test", suite.provider))
+
+	// The manager should hand its lifetime to the provider
+	manager, err := sessions.NewManager("redis-test", "cookie", 120)
+	suite.Nil(err)
+
+	w := httptest.NewRecorder()
+	s := manager.SessionStart(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	suite.NotNil(s)
+	suite.Equal(120*time.Second, suite.server.TTL(key(s.SessionID())))
+
+	// GC should return immediately rather than scheduling sweeps
+	done := make(chan bool)
+	go func() {
+		manager.GC()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		suite.Fail("GC did not return for a provider that expires sessions itself")
+	}
+	suite.True(suite.server.Exists(key(s.SessionID())))
+}
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(RedisTestSuite))
+}
@@ -0,0 +1,29 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Serializes session values for providers that persist sessions outside of memory.
+// Values of custom types must be registered with gob.Register before they are stored.
+func EncodeValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Deserializes session values produced by EncodeValues
+func DecodeValues(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if len(data) == 0 {
+		return values, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
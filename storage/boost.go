@@ -0,0 +1,9 @@
+package storage
+
+// A rule multiplying the rank of the posts that match it in a user's feeds, Type is one of the boost types of ranking
+type Boost struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	Value      string  `json:"value"`
+	Multiplier float64 `json:"multiplier"`
+}
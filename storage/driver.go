@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/iced-mocha/shared/models"
 )
 
@@ -13,8 +16,62 @@ type Driver interface {
 
 	UpdateWeights(username string, weights models.Weights) bool
 
+	// Gets the weight of every source the user has set, keyed by client name
+	GetWeights(username string) (map[string]float64, error)
+
+	// Sets the weights of the given sources, weights of other sources are left unchanged
+	UpdateSourceWeights(username string, weights map[string]float64) error
+
+	// Gets the name of the ranking strategy the user picked, empty when they have not picked one
+	GetRankingStrategy(username string) (string, error)
+
+	UpdateRankingStrategy(username, strategy string) error
+
+	// Records that the user has seen the posts with the given ids at time at
+	MarkSeen(username string, postIDs []string, at time.Time) error
+
+	// Gets the ids of the posts the user has seen since the given time
+	GetSeen(username string, since time.Time) (map[string]bool, error)
+
+	// Forgets the posts the user saw before the given time
+	DeleteSeen(username string, before time.Time) error
+
+	// Saves a copy of post for the user. Saving a post again updates the copy but keeps the time it was first saved.
+	SavePost(username string, post models.Post, at time.Time) error
+
+	// Removes a post from the user's saved posts, reporting whether it was saved
+	DeleteSavedPost(username, postID string) (bool, error)
+
+	// Gets up to limit of the user's saved posts, newest first, starting after the given cursor or from the start when nil
+	GetSavedPosts(username string, after *SavedCursor, limit int) ([]SavedPost, error)
+
+	// Gets every mute filter of the user
+	GetMuteFilters(username string) ([]MuteFilter, error)
+
+	InsertMuteFilter(username string, filter MuteFilter) error
+
+	// Removes a mute filter of the user, reporting whether it existed
+	DeleteMuteFilter(username, filterID string) (bool, error)
+
+	// Gets every boost of the user
+	GetBoosts(username string) ([]Boost, error)
+
+	InsertBoost(username string, boost Boost) error
+
+	// Removes a boost of the user, reporting whether it existed
+	DeleteBoost(username, boostID string) (bool, error)
+
 	UpdateRssFeeds(username string, feeds map[string][]string) error
 
+	// Gets a single rss group of the user, reporting whether it exists
+	GetRssGroup(username, name string) (RssGroup, bool, error)
+
+	// Creates the rss group of the user or replaces its feeds and weight if it already exists
+	PutRssGroup(username string, group RssGroup) error
+
+	// Removes an rss group of the user, reporting whether it existed
+	DeleteRssGroup(username, name string) (bool, error)
+
 	UpdateRedditAccount(userID, redditUser, authToken, refreshToken string) bool
 
 	UpdateTwitterAccount(userID, twitterUser, authToken, secret string) bool
@@ -22,4 +79,7 @@ type Driver interface {
 	UpdateFacebookAccount(userID, facebookUser, authToken string) bool
 
 	UpdateOAuthToken(userID, token, expiry string) bool
+
+	// Checks that the underlying database can be reached
+	Ping(ctx context.Context) error
 }
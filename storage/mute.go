@@ -0,0 +1,8 @@
+package storage
+
+// A rule hiding the posts that match it from a user's feeds, Type is one of the mute rule types of ranking
+type MuteFilter struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,11 @@
+package storage
+
+// The weight of rss groups created without one, the default of the Weight column of the Rss table
+const DefaultRssGroupWeight = 50.0
+
+// A named group of rss feeds whose posts are read and ranked together
+type RssGroup struct {
+	Name   string   `json:"name"`
+	Feeds  []string `json:"feeds"`
+	Weight float64  `json:"weight"`
+}
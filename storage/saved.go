@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/iced-mocha/shared/models"
+)
+
+// A post a user saved to read later
+type SavedPost struct {
+	models.Post
+	SavedAt time.Time `json:"saved-at"`
+}
+
+// Identifies a position in a user's saved posts, which are ordered by the time they were saved, newest
+// first, and then by post id
+type SavedCursor struct {
+	SavedAt time.Time
+	PostID  string
+}
@@ -0,0 +1,49 @@
+package sql
+
+import (
+	"log"
+
+	"github.com/iced-mocha/core/storage"
+)
+
+// Gets every boost of the user sorted by type and value
+func (d *driver) GetBoosts(username string) ([]storage.Boost, error) {
+	rows, err := d.db.Query("SELECT BoostID, Type, Value, Multiplier FROM Boosts WHERE Username=? ORDER BY Type, Value", username)
+	if err != nil {
+		log.Printf("Unable to get boosts for user %v: %v", username, err)
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	boosts := []storage.Boost{}
+	for rows.Next() {
+		var b storage.Boost
+		if err := rows.Scan(&b.ID, &b.Type, &b.Value, &b.Multiplier); err != nil {
+			return nil, err
+		}
+		boosts = append(boosts, b)
+	}
+	return boosts, rows.Err()
+}
+
+func (d *driver) InsertBoost(username string, boost storage.Boost) error {
+	_, err := d.db.Exec("INSERT INTO Boosts (Username, BoostID, Type, Value, Multiplier) VALUES (?,?,?,?,?)",
+		username, boost.ID, boost.Type, boost.Value, boost.Multiplier)
+	if err != nil {
+		log.Printf("Unable to insert boost for user %v: %v", username, err)
+	}
+	return err
+}
+
+// Removes a boost of the user, reporting whether it existed
+func (d *driver) DeleteBoost(username, boostID string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM Boosts WHERE Username=? AND BoostID=?", username, boostID)
+	if err != nil {
+		log.Printf("Unable to delete boost %v for user %v: %v", boostID, username, err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
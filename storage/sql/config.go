@@ -1,6 +1,10 @@
 package sql
 
+import "github.com/iced-mocha/core/secrets"
+
 type Config struct {
 	DatabasePath   string
 	DatabaseDriver string
+	// Used to encrypt the tokens of linked accounts at rest
+	Keyring *secrets.Keyring
 }
@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A dialect generates the statements that differ between the databases we support
+type dialect interface {
+	// Produces a statement inserting rows rows of columns into table. Rows whose keys already
+	// exist have their update columns set to the new values instead.
+	upsert(table string, keys, columns, update []string, rows int) string
+}
+
+// Dialects of every supported database/sql driver
+var dialects = map[string]dialect{
+	"sqlite3": sqliteDialect{},
+	"mysql":   mysqlDialect{},
+}
+
+func dialectFor(driverName string) (dialect, error) {
+	d, ok := dialects[driverName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %v", driverName)
+	}
+	return d, nil
+}
+
+// Produces the VALUES list for rows rows of n columns i.e (?,?),(?,?)
+func placeholders(n, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+",", rows), ",")
+}
+
+func insert(table string, columns []string, rows int) string {
+	return fmt.Sprintf("INSERT INTO %v (%v) VALUES %v", table, strings.Join(columns, ", "), placeholders(len(columns), rows))
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) upsert(table string, keys, columns, update []string, rows int) string {
+	sets := make([]string, len(update))
+	for i, c := range update {
+		sets[i] = fmt.Sprintf("%v=excluded.%v", c, c)
+	}
+	return fmt.Sprintf("%v ON CONFLICT (%v) DO UPDATE SET %v",
+		insert(table, columns, rows), strings.Join(keys, ", "), strings.Join(sets, ", "))
+}
+
+type mysqlDialect struct{}
+
+// mysql upserts on any unique key so keys are implied by the table
+func (mysqlDialect) upsert(table string, keys, columns, update []string, rows int) string {
+	sets := make([]string, len(update))
+	for i, c := range update {
+		sets[i] = fmt.Sprintf("%v=VALUES(%v)", c, c)
+	}
+	return fmt.Sprintf("%v ON DUPLICATE KEY UPDATE %v", insert(table, columns, rows), strings.Join(sets, ", "))
+}
@@ -0,0 +1,407 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const schemaVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL PRIMARY KEY,
+		description VARCHAR(255) NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`
+
+// A single change to the schema. Statements are keyed by database driver, the statements
+// under "" are used for any driver that does not have its own.
+type migration struct {
+	version     int
+	description string
+	up          map[string][]string
+	down        map[string][]string
+}
+
+// The current state of a single migration
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// Every migration in the order it is applied. Migrations must never be edited once released,
+// changes to the schema are made by appending a new migration.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create user and rss tables",
+		// Tables may already exist in databases created before migrations were introduced
+		up: map[string][]string{"": {`
+			CREATE TABLE IF NOT EXISTS UserInfo (
+				UserID VARCHAR(64) PRIMARY KEY,
+				Username VARCHAR(64) NOT NULL,
+				Password VARCHAR(64) NOT NULL,
+				TwitterUsername VARCHAR(64) NOT NULL DEFAULT '',
+				TwitterAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+				TwitterSecret VARCHAR(64) NOT NULL DEFAULT '',
+				RedditUsername VARCHAR(64) NOT NULL DEFAULT '',
+				RedditAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+				RedditRefreshToken VARCHAR(64) NULL DEFAULT '',
+				FacebookUsername VARCHAR(64) NOT NULL DEFAULT '',
+				FacebookAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+				RedditWeight FLOAT NOT NULL DEFAULT 0,
+				FacebookWeight FLOAT NOT NULL DEFAULT 0,
+				HackerNewsWeight FLOAT NOT NULL DEFAULT 0,
+				GoogleNewsWeight FLOAT NOT NULL DEFAULT 0,
+				TwitterWeight FLOAT NOT NULL DEFAULT 0
+			)`, `
+			CREATE TABLE IF NOT EXISTS Rss (
+				Username VARCHAR(64) NOT NULL,
+				Feeds TEXT NOT NULL,
+				Weight FLOAT NOT NULL DEFAULT 50,
+				Name VARCHAR(64) NOT NULL,
+				PRIMARY KEY (Username, Name)
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Rss",
+			"DROP TABLE UserInfo",
+		}},
+	},
+	{
+		version:     2,
+		description: "create sessions table",
+		up: map[string][]string{"": {`
+			CREATE TABLE IF NOT EXISTS Sessions (
+				SessionID VARCHAR(64) PRIMARY KEY,
+				Data BLOB NOT NULL,
+				LastAccessed BIGINT NOT NULL
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Sessions",
+		}},
+	},
+	{
+		version:     3,
+		description: "move source weights into the weights table",
+		up: map[string][]string{
+			// sqlite cannot drop columns so UserInfo is rebuilt without them
+			"sqlite3": append(createWeights, `
+				CREATE TABLE UserInfoNew (
+					UserID VARCHAR(64) PRIMARY KEY,
+					Username VARCHAR(64) NOT NULL,
+					Password VARCHAR(64) NOT NULL,
+					TwitterUsername VARCHAR(64) NOT NULL DEFAULT '',
+					TwitterAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+					TwitterSecret VARCHAR(64) NOT NULL DEFAULT '',
+					RedditUsername VARCHAR(64) NOT NULL DEFAULT '',
+					RedditAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+					RedditRefreshToken VARCHAR(64) NULL DEFAULT '',
+					FacebookUsername VARCHAR(64) NOT NULL DEFAULT '',
+					FacebookAuthToken VARCHAR(64) NOT NULL DEFAULT ''
+				)`, `
+				INSERT INTO UserInfoNew
+				SELECT UserID, Username, Password, TwitterUsername, TwitterAuthToken, TwitterSecret,
+					RedditUsername, RedditAuthToken, RedditRefreshToken, FacebookUsername, FacebookAuthToken
+				FROM UserInfo`,
+				"DROP TABLE UserInfo",
+				"ALTER TABLE UserInfoNew RENAME TO UserInfo",
+			),
+			"": append(createWeights, `
+				ALTER TABLE UserInfo
+					DROP COLUMN RedditWeight,
+					DROP COLUMN FacebookWeight,
+					DROP COLUMN HackerNewsWeight,
+					DROP COLUMN GoogleNewsWeight,
+					DROP COLUMN TwitterWeight`,
+			),
+		},
+		down: map[string][]string{"": {
+			"ALTER TABLE UserInfo ADD COLUMN RedditWeight FLOAT NOT NULL DEFAULT 0",
+			"ALTER TABLE UserInfo ADD COLUMN FacebookWeight FLOAT NOT NULL DEFAULT 0",
+			"ALTER TABLE UserInfo ADD COLUMN HackerNewsWeight FLOAT NOT NULL DEFAULT 0",
+			"ALTER TABLE UserInfo ADD COLUMN GoogleNewsWeight FLOAT NOT NULL DEFAULT 0",
+			"ALTER TABLE UserInfo ADD COLUMN TwitterWeight FLOAT NOT NULL DEFAULT 0",
+			restoreWeight("RedditWeight", "reddit"),
+			restoreWeight("FacebookWeight", "facebook"),
+			restoreWeight("HackerNewsWeight", "hacker-news"),
+			restoreWeight("GoogleNewsWeight", "google-news"),
+			restoreWeight("TwitterWeight", "twitter"),
+			"DROP TABLE Weights",
+		}},
+	},
+	{
+		version:     4,
+		description: "widen token columns to fit encrypted tokens",
+		// sqlite does not enforce the length of VARCHAR columns
+		up: map[string][]string{"sqlite3": {}, "": {`
+			ALTER TABLE UserInfo
+				MODIFY TwitterAuthToken VARCHAR(512) NOT NULL DEFAULT '',
+				MODIFY TwitterSecret VARCHAR(512) NOT NULL DEFAULT '',
+				MODIFY RedditAuthToken VARCHAR(512) NOT NULL DEFAULT '',
+				MODIFY RedditRefreshToken VARCHAR(512) NULL DEFAULT '',
+				MODIFY FacebookAuthToken VARCHAR(512) NOT NULL DEFAULT ''`,
+		}},
+		// Encrypted tokens do not fit in the narrower columns, only roll back before any were stored
+		down: map[string][]string{"sqlite3": {}, "": {`
+			ALTER TABLE UserInfo
+				MODIFY TwitterAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+				MODIFY TwitterSecret VARCHAR(64) NOT NULL DEFAULT '',
+				MODIFY RedditAuthToken VARCHAR(64) NOT NULL DEFAULT '',
+				MODIFY RedditRefreshToken VARCHAR(64) NULL DEFAULT '',
+				MODIFY FacebookAuthToken VARCHAR(64) NOT NULL DEFAULT ''`,
+		}},
+	},
+	{
+		version:     5,
+		description: "create preferences table",
+		// Kept out of UserInfo so preferences can be rolled back without rebuilding it on sqlite
+		up: map[string][]string{"": {`
+			CREATE TABLE Preferences (
+				Username VARCHAR(64) PRIMARY KEY,
+				RankingStrategy VARCHAR(32) NOT NULL DEFAULT ''
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Preferences",
+		}},
+	},
+	{
+		version:     6,
+		description: "create seen posts table",
+		up: map[string][]string{"": {`
+			CREATE TABLE Seen (
+				Username VARCHAR(64) NOT NULL,
+				PostID VARCHAR(255) NOT NULL,
+				SeenAt BIGINT NOT NULL,
+				PRIMARY KEY (Username, PostID)
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Seen",
+		}},
+	},
+	{
+		version:     7,
+		description: "create saved posts table",
+		// Posts are copied in full so they can still be shown once their source has deleted them
+		up: map[string][]string{"": {`
+			CREATE TABLE Saved (
+				Username VARCHAR(64) NOT NULL,
+				PostID VARCHAR(255) NOT NULL,
+				SavedAt BIGINT NOT NULL,
+				Date BIGINT NOT NULL,
+				Author VARCHAR(255) NOT NULL DEFAULT '',
+				Title TEXT NOT NULL,
+				Content TEXT NOT NULL,
+				HeroImg TEXT NOT NULL,
+				PostLink TEXT NOT NULL,
+				Platform VARCHAR(64) NOT NULL DEFAULT '',
+				Score INTEGER NOT NULL DEFAULT 0,
+				Subreddit VARCHAR(255) NOT NULL DEFAULT '',
+				PRIMARY KEY (Username, PostID)
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Saved",
+		}},
+	},
+	{
+		version:     8,
+		description: "create mute filters table",
+		up: map[string][]string{"": {`
+			CREATE TABLE MuteFilters (
+				Username VARCHAR(64) NOT NULL,
+				FilterID VARCHAR(64) NOT NULL,
+				Type VARCHAR(32) NOT NULL,
+				Value VARCHAR(512) NOT NULL,
+				PRIMARY KEY (Username, FilterID)
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE MuteFilters",
+		}},
+	},
+	{
+		version:     9,
+		description: "create boosts table",
+		up: map[string][]string{"": {`
+			CREATE TABLE Boosts (
+				Username VARCHAR(64) NOT NULL,
+				BoostID VARCHAR(64) NOT NULL,
+				Type VARCHAR(32) NOT NULL,
+				Value VARCHAR(512) NOT NULL,
+				Multiplier FLOAT NOT NULL DEFAULT 1,
+				PRIMARY KEY (Username, BoostID)
+			)`,
+		}},
+		down: map[string][]string{"": {
+			"DROP TABLE Boosts",
+		}},
+	},
+}
+
+// Creates the weights table and copies the weights out of the UserInfo columns
+var createWeights = []string{`
+	CREATE TABLE Weights (
+		Username VARCHAR(64) NOT NULL,
+		Source VARCHAR(64) NOT NULL,
+		Weight FLOAT NOT NULL DEFAULT 0,
+		PRIMARY KEY (Username, Source)
+	)`,
+	"INSERT INTO Weights (Username, Source, Weight) SELECT Username, 'reddit', RedditWeight FROM UserInfo",
+	"INSERT INTO Weights (Username, Source, Weight) SELECT Username, 'facebook', FacebookWeight FROM UserInfo",
+	"INSERT INTO Weights (Username, Source, Weight) SELECT Username, 'hacker-news', HackerNewsWeight FROM UserInfo",
+	"INSERT INTO Weights (Username, Source, Weight) SELECT Username, 'google-news', GoogleNewsWeight FROM UserInfo",
+	"INSERT INTO Weights (Username, Source, Weight) SELECT Username, 'twitter', TwitterWeight FROM UserInfo",
+}
+
+// Copies the weight of source back into column of UserInfo
+func restoreWeight(column, source string) string {
+	return fmt.Sprintf(`
+		UPDATE UserInfo SET %v = COALESCE(
+			(SELECT Weight FROM Weights WHERE Weights.Username = UserInfo.Username AND Source = '%v'), 0
+		)`, column, source)
+}
+
+// Produces the statements of m for the given database driver
+func statements(stmts map[string][]string, driver string) []string {
+	if s, ok := stmts[driver]; ok {
+		return s
+	}
+	return stmts[""]
+}
+
+// Gets the versions of all applied migrations along with when they were applied
+func (d *driver) appliedMigrations() (map[int]time.Time, error) {
+	if _, err := d.db.Exec(schemaVersionTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = time.Unix(appliedAt, 0)
+	}
+
+	return applied, rows.Err()
+}
+
+// Runs the statements of a migration and records its new version, all in one transaction.
+// Note mysql commits schema changes implicitly so a failed migration there may need manual repair.
+func (d *driver) runMigration(stmts []string, record string, args ...interface{}) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Applies every migration that has not yet been applied to the database
+func (d *driver) MigrateUp() error {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %v: %v", m.version, m.description)
+		err := d.runMigration(statements(m.up, d.dbDriver), `
+			INSERT INTO schema_version (version, description, applied_at) VALUES (?,?,?)`,
+			m.version, m.description, time.Now().Unix())
+		if err != nil {
+			return fmt.Errorf("Unable to apply migration %v: %v", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// Rolls back the most recently applied migration, if any
+func (d *driver) MigrateDown() error {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+
+		log.Printf("Rolling back migration %v: %v", m.version, m.description)
+		err := d.runMigration(statements(m.down, d.dbDriver), "DELETE FROM schema_version WHERE version=?", m.version)
+		if err != nil {
+			return fmt.Errorf("Unable to roll back migration %v: %v", m.version, err)
+		}
+		return nil
+	}
+
+	log.Printf("No migrations to roll back")
+	return nil
+}
+
+// Produces the status of every known migration in the order they are applied
+func (d *driver) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.version]
+		statuses = append(statuses, MigrationStatus{
+			Version:     m.version,
+			Description: m.description,
+			Applied:     ok,
+			AppliedAt:   appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Produces the version of the most recently applied migration, 0 if none have been applied
+func (d *driver) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if _, err := d.db.Exec(schemaVersionTable); err != nil {
+		return 0, err
+	}
+	if err := d.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
@@ -0,0 +1,144 @@
+package sql
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// The schema created by scripts/initDB.sql before core managed its own schema
+const legacySchema = `
+	CREATE TABLE UserInfo (
+		UserID VARCHAR(64) PRIMARY KEY,
+		Username VARCHAR(64) NOT NULL,
+		Password VARCHAR(64) NOT NULL,
+		TwitterUsername VARCHAR(64) NOT NULL DEFAULT "",
+		TwitterAuthToken VARCHAR(64) NOT NULL DEFAULT "",
+		TwitterSecret VARCHAR(64) NOT NULL DEFAULT "",
+		RedditUsername VARCHAR(64) NOT NULL DEFAULT "",
+		RedditAuthToken VARCHAR(64) NOT NULL DEFAULT "",
+		RedditRefreshToken VARCHAR(64) NULL DEFAULT "",
+		FacebookUsername VARCHAR(64) NOT NULL DEFAULT "",
+		FacebookAuthToken VARCHAR(64) NOT NULL DEFAULT "",
+		RedditWeight FLOAT NOT NULL DEFAULT 0,
+		FacebookWeight FLOAT NOT NULL DEFAULT 0,
+		HackerNewsWeight FLOAT NOT NULL DEFAULT 0,
+		GoogleNewsWeight FLOAT NOT NULL DEFAULT 0,
+		TwitterWeight FLOAT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE Rss (
+		Username VARCHAR(64) NOT NULL,
+		Feeds TEXT NOT NULL,
+		Weight FLOAT NOT NULL DEFAULT 50,
+		Name VARCHAR(64) NOT NULL,
+		PRIMARY KEY (Username, Name)
+	);
+`
+
+type MigrationsTestSuite struct {
+	suite.Suite
+	d      *driver
+	dbFile string
+}
+
+func (suite *MigrationsTestSuite) SetupSuite() {
+	log.SetOutput(ioutil.Discard)
+}
+
+func (suite *MigrationsTestSuite) SetupTest() {
+	f, err := ioutil.TempFile("", "migrations")
+	suite.Nil(err)
+	suite.Nil(f.Close())
+	suite.dbFile = f.Name()
+
+	suite.d, err = New(Config{DatabasePath: suite.dbFile})
+	suite.Nil(err)
+}
+
+func (suite *MigrationsTestSuite) TearDownTest() {
+	suite.Nil(suite.d.db.Close())
+	suite.Nil(os.Remove(suite.dbFile))
+}
+
+func (suite *MigrationsTestSuite) TestUpAndDown() {
+	version, err := suite.d.SchemaVersion()
+	suite.Nil(err)
+	suite.Equal(0, version)
+
+	suite.Nil(suite.d.MigrateUp())
+	version, err = suite.d.SchemaVersion()
+	suite.Nil(err)
+	suite.Equal(migrations[len(migrations)-1].version, version)
+
+	// Applying migrations again does nothing
+	suite.Nil(suite.d.MigrateUp())
+
+	statuses, err := suite.d.MigrationStatus()
+	suite.Nil(err)
+	suite.Len(statuses, len(migrations))
+	for _, s := range statuses {
+		suite.True(s.Applied)
+	}
+
+	// Every migration can be rolled back one at a time
+	for i := len(migrations) - 1; i >= 0; i-- {
+		suite.Nil(suite.d.MigrateDown())
+		version, err = suite.d.SchemaVersion()
+		suite.Nil(err)
+		if i > 0 {
+			suite.Equal(migrations[i-1].version, version)
+		} else {
+			suite.Equal(0, version)
+		}
+	}
+
+	statuses, err = suite.d.MigrationStatus()
+	suite.Nil(err)
+	for _, s := range statuses {
+		suite.False(s.Applied)
+	}
+
+	// Rolling back with nothing applied is not an error
+	suite.Nil(suite.d.MigrateDown())
+	suite.Nil(suite.d.MigrateUp())
+}
+
+func (suite *MigrationsTestSuite) TestLegacyDatabase() {
+	_, err := suite.d.db.Exec(legacySchema)
+	suite.Nil(err)
+	_, err = suite.d.db.Exec(`
+		INSERT INTO UserInfo (UserID, Username, Password, RedditWeight, TwitterWeight)
+		VALUES ('id', 'jgore', 'hash', 20, 5)`)
+	suite.Nil(err)
+
+	suite.Nil(suite.d.MigrateUp())
+
+	// Weights are moved out of the UserInfo columns
+	weights, err := suite.d.GetWeights("jgore")
+	suite.Nil(err)
+	suite.Equal(20.0, weights["reddit"])
+	suite.Equal(5.0, weights["twitter"])
+	suite.Equal(0.0, weights["facebook"])
+
+	user, exists, err := suite.d.GetUser("jgore")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal("id", user.ID)
+	suite.Equal(20.0, user.PostWeights.Reddit)
+
+	// Rolling back the weights migration restores the columns
+	for version, err := suite.d.SchemaVersion(); version >= 3; version, err = suite.d.SchemaVersion() {
+		suite.Nil(err)
+		suite.Nil(suite.d.MigrateDown())
+	}
+	var reddit float64
+	suite.Nil(suite.d.db.QueryRow("SELECT RedditWeight FROM UserInfo WHERE Username='jgore'").Scan(&reddit))
+	suite.Equal(20.0, reddit)
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
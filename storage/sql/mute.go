@@ -0,0 +1,49 @@
+package sql
+
+import (
+	"log"
+
+	"github.com/iced-mocha/core/storage"
+)
+
+// Gets every mute filter of the user sorted by type and value
+func (d *driver) GetMuteFilters(username string) ([]storage.MuteFilter, error) {
+	rows, err := d.db.Query("SELECT FilterID, Type, Value FROM MuteFilters WHERE Username=? ORDER BY Type, Value", username)
+	if err != nil {
+		log.Printf("Unable to get mute filters for user %v: %v", username, err)
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	filters := []storage.MuteFilter{}
+	for rows.Next() {
+		var f storage.MuteFilter
+		if err := rows.Scan(&f.ID, &f.Type, &f.Value); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, rows.Err()
+}
+
+func (d *driver) InsertMuteFilter(username string, filter storage.MuteFilter) error {
+	_, err := d.db.Exec("INSERT INTO MuteFilters (Username, FilterID, Type, Value) VALUES (?,?,?,?)",
+		username, filter.ID, filter.Type, filter.Value)
+	if err != nil {
+		log.Printf("Unable to insert mute filter for user %v: %v", username, err)
+	}
+	return err
+}
+
+// Removes a mute filter of the user, reporting whether it existed
+func (d *driver) DeleteMuteFilter(username, filterID string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM MuteFilters WHERE Username=? AND FilterID=?", username, filterID)
+	if err != nil {
+		log.Printf("Unable to delete mute filter %v for user %v: %v", filterID, username, err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
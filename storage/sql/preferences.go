@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// Gets the name of the ranking strategy the user picked, empty when they have not picked one
+func (d *driver) GetRankingStrategy(username string) (string, error) {
+	var strategy string
+	err := d.db.QueryRow("SELECT RankingStrategy FROM Preferences WHERE Username=?", username).Scan(&strategy)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		log.Printf("Unable to get ranking strategy for user %v: %v", username, err)
+		return "", err
+	}
+	return strategy, nil
+}
+
+// Sets the ranking strategy of the user, an empty strategy goes back to the default
+func (d *driver) UpdateRankingStrategy(username, strategy string) (err error) {
+	log.Printf("Preparing to update ranking strategy for user: %v", username)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	// A transaction that fails to commit fails the whole update
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	var exists bool
+	if exists, err = userExists(tx, username); err != nil {
+		return err
+	} else if !exists {
+		err = errors.New("No user found in database with given username: " + username)
+		return err
+	}
+
+	_, err = tx.Exec(d.dialect.upsert("Preferences", []string{"Username"},
+		[]string{"Username", "RankingStrategy"}, []string{"RankingStrategy"}, 1), username, strategy)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
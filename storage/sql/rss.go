@@ -0,0 +1,51 @@
+package sql
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/iced-mocha/core/storage"
+)
+
+// Gets a single rss group of the user, reporting whether it exists
+func (d *driver) GetRssGroup(username, name string) (storage.RssGroup, bool, error) {
+	group := storage.RssGroup{Name: name, Feeds: []string{}}
+
+	var feeds string
+	err := d.db.QueryRow("SELECT Feeds, Weight FROM Rss WHERE Username=? AND Name=?", username, name).Scan(&feeds, &group.Weight)
+	if err == sql.ErrNoRows {
+		return group, false, nil
+	} else if err != nil {
+		log.Printf("Unable to get rss group %v for user %v: %v", name, username, err)
+		return group, false, err
+	}
+
+	if feeds != "" {
+		group.Feeds = strings.Split(feeds, ",")
+	}
+	return group, true, nil
+}
+
+// Creates the rss group of the user or replaces its feeds and weight if it already exists
+func (d *driver) PutRssGroup(username string, group storage.RssGroup) error {
+	_, err := d.db.Exec(d.dialect.upsert("Rss", []string{"Username", "Name"},
+		[]string{"Username", "Feeds", "Weight", "Name"}, []string{"Feeds", "Weight"}, 1),
+		username, strings.Join(group.Feeds, ","), group.Weight, group.Name)
+	if err != nil {
+		log.Printf("Unable to put rss group %v for user %v: %v", group.Name, username, err)
+	}
+	return err
+}
+
+// Removes an rss group of the user, reporting whether it existed
+func (d *driver) DeleteRssGroup(username, name string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM Rss WHERE Username=? AND Name=?", username, name)
+	if err != nil {
+		log.Printf("Unable to delete rss group %v for user %v: %v", name, username, err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
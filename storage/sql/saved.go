@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"log"
+	"time"
+
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
+)
+
+// Saves a copy of post for the user. Saving a post again updates the copy but keeps the time it was first saved.
+func (d *driver) SavePost(username string, post models.Post, at time.Time) error {
+	// Zero times cannot be represented in nanoseconds since the epoch
+	date := int64(0)
+	if !post.Date.IsZero() {
+		date = post.Date.UnixNano()
+	}
+
+	columns := []string{"Username", "PostID", "SavedAt", "Date", "Author", "Title", "Content", "HeroImg", "PostLink", "Platform", "Score", "Subreddit"}
+	_, err := d.db.Exec(d.dialect.upsert("Saved", []string{"Username", "PostID"}, columns, columns[3:], 1),
+		username, post.ID, at.UnixNano(), date, post.Author, post.Title, post.Content,
+		post.HeroImg, post.PostLink, post.Platform, post.Score, post.Subreddit)
+	if err != nil {
+		log.Printf("Unable to save post %v for user %v: %v", post.ID, username, err)
+	}
+	return err
+}
+
+// Removes a post from the user's saved posts, reporting whether it was saved
+func (d *driver) DeleteSavedPost(username, postID string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM Saved WHERE Username=? AND PostID=?", username, postID)
+	if err != nil {
+		log.Printf("Unable to delete saved post %v for user %v: %v", postID, username, err)
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Gets up to limit of the user's saved posts, newest first, starting after the given cursor or from the start when nil
+func (d *driver) GetSavedPosts(username string, after *storage.SavedCursor, limit int) ([]storage.SavedPost, error) {
+	query := `
+		SELECT PostID, SavedAt, Date, Author, Title, Content, HeroImg, PostLink, Platform, Score, Subreddit
+		FROM Saved WHERE Username=?`
+	args := []interface{}{username}
+	if after != nil {
+		query += " AND (SavedAt < ? OR (SavedAt = ? AND PostID > ?))"
+		args = append(args, after.SavedAt.UnixNano(), after.SavedAt.UnixNano(), after.PostID)
+	}
+	query += " ORDER BY SavedAt DESC, PostID ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Unable to get saved posts for user %v: %v", username, err)
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	posts := []storage.SavedPost{}
+	for rows.Next() {
+		var p storage.SavedPost
+		var savedAt, date int64
+		err := rows.Scan(&p.ID, &savedAt, &date, &p.Author, &p.Title, &p.Content, &p.HeroImg,
+			&p.PostLink, &p.Platform, &p.Score, &p.Subreddit)
+		if err != nil {
+			return nil, err
+		}
+		p.SavedAt = time.Unix(0, savedAt)
+		if date != 0 {
+			p.Date = time.Unix(0, date)
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
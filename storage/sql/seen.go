@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"log"
+	"time"
+)
+
+// Records that the user has seen the posts with the given ids at time at
+func (d *driver) MarkSeen(username string, postIDs []string, at time.Time) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 3*len(postIDs))
+	for _, id := range postIDs {
+		args = append(args, username, id, at.Unix())
+	}
+
+	_, err := d.db.Exec(d.dialect.upsert("Seen", []string{"Username", "PostID"},
+		[]string{"Username", "PostID", "SeenAt"}, []string{"SeenAt"}, len(postIDs)), args...)
+	if err != nil {
+		log.Printf("Unable to mark posts seen for user %v: %v", username, err)
+	}
+	return err
+}
+
+// Gets the ids of the posts the user has seen since the given time
+func (d *driver) GetSeen(username string, since time.Time) (map[string]bool, error) {
+	rows, err := d.db.Query("SELECT PostID FROM Seen WHERE Username=? AND SeenAt >= ?", username, since.Unix())
+	if err != nil {
+		log.Printf("Unable to get seen posts for user %v: %v", username, err)
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		seen[id] = true
+	}
+	return seen, rows.Err()
+}
+
+// Forgets the posts the user saw before the given time
+func (d *driver) DeleteSeen(username string, before time.Time) error {
+	_, err := d.db.Exec("DELETE FROM Seen WHERE Username=? AND SeenAt < ?", username, before.Unix())
+	if err != nil {
+		log.Printf("Unable to delete seen posts for user %v: %v", username, err)
+	}
+	return err
+}
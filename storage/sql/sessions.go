@@ -0,0 +1,151 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iced-mocha/core/sessions"
+)
+
+// Implementation of the sessions.Session interface whose values are written through to the database
+type session struct {
+	sid      string
+	lock     sync.Mutex
+	values   map[string]interface{}
+	provider *sessionProvider
+}
+
+// Set a value in the session store
+func (s *session) Set(key string, value interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values[key] = value
+	return s.provider.save(s)
+}
+
+func (s *session) Get(key string) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (s *session) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values, key)
+	return s.provider.save(s)
+}
+
+// Get the id of a given session
+func (s *session) SessionID() string {
+	return s.sid
+}
+
+// How long sessions live until the session manager sets their lifetime
+const defaultSessionLifetime = 3600
+
+// Session provider that stores sessions in the Sessions table so they survive restarts of core
+type sessionProvider struct {
+	db *sql.DB
+	// Sessions that have not been accessed for this many seconds have expired, even before they are collected
+	maxlifetime int64
+}
+
+// Produces a session provider backed by the same database as the driver
+func (d *driver) SessionProvider() (sessions.Provider, error) {
+	// The Sessions table is created by the migrations, see MigrateUp
+	return &sessionProvider{db: d.db, maxlifetime: defaultSessionLifetime}, nil
+}
+
+// Sets how many seconds sessions live for after they were last accessed
+func (p *sessionProvider) SetSessionLifetime(maxlifetime int64) {
+	p.maxlifetime = maxlifetime
+}
+
+// Creates a new session and stores it in the database
+func (p *sessionProvider) SessionInit(sid string) (sessions.Session, error) {
+	s := &session{sid: sid, values: make(map[string]interface{}), provider: p}
+	data, err := sessions.EncodeValues(s.values)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.db.Exec("INSERT INTO Sessions (SessionID, Data, LastAccessed) VALUES (?,?,?)", sid, data, time.Now().Unix())
+	if err != nil {
+		log.Printf("Unable to insert session: %v", err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Produces the session associated with the given session id, provided it has not expired
+func (p *sessionProvider) SessionRead(sid string) (sessions.Session, error) {
+	var data []byte
+	err := p.db.QueryRow("SELECT Data FROM Sessions WHERE SessionID=? AND LastAccessed >= ?",
+		sid, time.Now().Unix()-p.maxlifetime).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no such session %v", sid)
+	} else if err != nil {
+		return nil, err
+	}
+
+	values, err := sessions.DecodeValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading a session counts as accessing it
+	if err := p.SessionUpdate(sid); err != nil {
+		return nil, err
+	}
+
+	return &session{sid: sid, values: values, provider: p}, nil
+}
+
+// Destroys the session associated with the given id
+func (p *sessionProvider) SessionDestroy(sid string) error {
+	_, err := p.db.Exec("DELETE FROM Sessions WHERE SessionID=?", sid)
+	return err
+}
+
+// Deletes every session that has not been accessed within maxlifetime seconds
+func (p *sessionProvider) SessionGC(maxlifetime int64) {
+	_, err := p.db.Exec("DELETE FROM Sessions WHERE LastAccessed < ?", time.Now().Unix()-maxlifetime)
+	if err != nil {
+		log.Printf("Unable to garbage collect sessions: %v", err)
+	}
+}
+
+// Updates the session access time to time.Now()
+func (p *sessionProvider) SessionUpdate(sid string) error {
+	_, err := p.db.Exec("UPDATE Sessions SET LastAccessed=? WHERE SessionID=?", time.Now().Unix(), sid)
+	return err
+}
+
+// Counts the sessions accessed within the last maxlifetime seconds
+func (p *sessionProvider) SessionCount(maxlifetime int64) (int, error) {
+	var count int
+	err := p.db.QueryRow("SELECT COUNT(*) FROM Sessions WHERE LastAccessed >= ?", time.Now().Unix()-maxlifetime).Scan(&count)
+	return count, err
+}
+
+// Writes the values of the session to the database
+func (p *sessionProvider) save(s *session) error {
+	data, err := sessions.EncodeValues(s.values)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.Exec("UPDATE Sessions SET Data=?, LastAccessed=? WHERE SessionID=?", data, time.Now().Unix(), s.sid)
+	if err != nil {
+		log.Printf("Unable to save session: %v", err)
+	}
+	return err
+}
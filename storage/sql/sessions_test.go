@@ -0,0 +1,163 @@
+package sql
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iced-mocha/core/sessions"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionsTestSuite struct {
+	suite.Suite
+	d        *driver
+	dbFile   string
+	provider sessions.Provider
+}
+
+func (suite *SessionsTestSuite) SetupSuite() {
+	log.SetOutput(ioutil.Discard)
+
+	f, err := ioutil.TempFile("", "sessions")
+	suite.Nil(err)
+	suite.Nil(f.Close())
+	suite.dbFile = f.Name()
+
+	suite.d, err = New(Config{DatabasePath: suite.dbFile})
+	suite.Nil(err)
+	suite.Nil(suite.d.MigrateUp())
+}
+
+func (suite *SessionsTestSuite) TearDownSuite() {
+	suite.Nil(os.Remove(suite.dbFile))
+}
+
+func (suite *SessionsTestSuite) SetupTest() {
+	var err error
+	suite.provider, err = suite.d.SessionProvider()
+	suite.Nil(err)
+
+	_, err = suite.d.db.Exec("DELETE FROM Sessions")
+	suite.Nil(err)
+}
+
+func (suite *SessionsTestSuite) TestSessionPersists() {
+	s, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+	suite.Equal("sid", s.SessionID())
+	suite.Nil(s.Set("username", "jack"))
+	suite.Nil(s.Set("count", 3))
+
+	// A new provider on the same database (i.e after a restart) should see the same session
+	provider, err := suite.d.SessionProvider()
+	suite.Nil(err)
+	s, err = provider.SessionRead("sid")
+	suite.Nil(err)
+	suite.Equal("jack", s.Get("username"))
+	suite.Equal(3, s.Get("count"))
+	suite.Nil(s.Get("missing"))
+
+	// Deleted values should stay deleted
+	suite.Nil(s.Delete("count"))
+	s, err = suite.provider.SessionRead("sid")
+	suite.Nil(err)
+	suite.Nil(s.Get("count"))
+	suite.Equal("jack", s.Get("username"))
+}
+
+func (suite *SessionsTestSuite) TestSessionRead() {
+	// Reading a session that was never created should fail
+	_, err := suite.provider.SessionRead("missing")
+	suite.NotNil(err)
+
+	// Sessions should be readable immediately after creation
+	_, err = suite.provider.SessionInit("sid")
+	suite.Nil(err)
+	s, err := suite.provider.SessionRead("sid")
+	suite.Nil(err)
+	suite.Nil(s.Get("username"))
+}
+
+func (suite *SessionsTestSuite) TestSessionExpiry() {
+	suite.provider.(sessions.SweepingProvider).SetSessionLifetime(60)
+	_, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+
+	// Sessions that have not been accessed within their lifetime cannot be read, even before they are collected
+	_, err = suite.d.db.Exec("UPDATE Sessions SET LastAccessed=? WHERE SessionID=?", time.Now().Add(-time.Hour).Unix(), "sid")
+	suite.Nil(err)
+	_, err = suite.provider.SessionRead("sid")
+	suite.NotNil(err)
+
+	// Reading an expired session does not extend it
+	_, err = suite.provider.SessionRead("sid")
+	suite.NotNil(err)
+
+	// Sessions live for longer when their lifetime is
+	suite.provider.(sessions.SweepingProvider).SetSessionLifetime(2 * 3600)
+	_, err = suite.provider.SessionRead("sid")
+	suite.Nil(err)
+}
+
+func (suite *SessionsTestSuite) TestSessionDestroy() {
+	_, err := suite.provider.SessionInit("sid")
+	suite.Nil(err)
+
+	suite.Nil(suite.provider.SessionDestroy("sid"))
+	_, err = suite.provider.SessionRead("sid")
+	suite.NotNil(err)
+
+	// Destroying a session that doesnt exist is not an error
+	suite.Nil(suite.provider.SessionDestroy("sid"))
+}
+
+func (suite *SessionsTestSuite) TestSessionGC() {
+	// The session manager only sweeps providers that do not expire sessions themselves
+	_, expiring := suite.provider.(sessions.ExpiringProvider)
+	suite.False(expiring)
+
+	_, err := suite.provider.SessionInit("stale")
+	suite.Nil(err)
+	_, err = suite.provider.SessionInit("fresh")
+	suite.Nil(err)
+
+	// Pretend the stale session was last accessed an hour ago
+	_, err = suite.d.db.Exec("UPDATE Sessions SET LastAccessed=? WHERE SessionID=?", time.Now().Add(-time.Hour).Unix(), "stale")
+	suite.Nil(err)
+
+	suite.provider.SessionGC(60)
+
+	_, err = suite.provider.SessionRead("stale")
+	suite.NotNil(err)
+	_, err = suite.provider.SessionRead("fresh")
+	suite.Nil(err)
+
+	// Updating a session should keep it from being collected
+	_, err = suite.d.db.Exec("UPDATE Sessions SET LastAccessed=? WHERE SessionID=?", time.Now().Add(-time.Hour).Unix(), "fresh")
+	suite.Nil(err)
+	suite.Nil(suite.provider.SessionUpdate("fresh"))
+	suite.provider.SessionGC(60)
+	_, err = suite.provider.SessionRead("fresh")
+	suite.Nil(err)
+}
+
+func (suite *SessionsTestSuite) TestSessionCount() {
+	_, err := suite.provider.SessionInit("stale")
+	suite.Nil(err)
+	_, err = suite.provider.SessionInit("fresh")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("UPDATE Sessions SET LastAccessed=? WHERE SessionID=?", time.Now().Add(-time.Hour).Unix(), "stale")
+	suite.Nil(err)
+
+	// Sessions that have expired but not been collected yet are not active
+	count, err := suite.provider.(sessions.CountingProvider).SessionCount(60)
+	suite.Nil(err)
+	suite.Equal(1, count)
+}
+
+func TestSessionsSuite(t *testing.T) {
+	suite.Run(t, new(SessionsTestSuite))
+}
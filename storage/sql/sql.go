@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/iced-mocha/core/secrets"
 	"github.com/iced-mocha/shared/models"
 	_ "github.com/mattn/go-sqlite3"
 	_ "github.com/twinj/uuid"
@@ -20,6 +22,11 @@ const (
 
 type driver struct {
 	db *sql.DB
+	// The name of the database/sql driver, used to pick dialect specific statements
+	dbDriver string
+	dialect  dialect
+	// Encrypts linked account tokens, tokens are stored in plaintext when nil
+	keyring *secrets.Keyring
 }
 
 type NullString sql.NullString
@@ -43,48 +50,45 @@ func (ns *NullString) Scan(value interface{}) error {
 
 // Inserts a user into the database
 // NOTE: This assumes the password of the user object has already been hashed
-func (d *driver) InsertUser(user models.User) error {
+func (d *driver) InsertUser(user models.User) (err error) {
 	log.Printf("Inserting user with ID: %v, and username: %v", user.ID, user.Username)
-	var err error
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
+	// A transaction that fails to commit fails the whole update
 	defer func() {
 		if err == nil {
-			tx.Commit()
+			err = tx.Commit()
 		} else {
 			tx.Rollback()
 		}
 	}()
 
-	pw := user.PostWeights
 	_, err = tx.Exec(`
-		INSERT INTO UserInfo (
-			UserID, Username, Password,
-			RedditWeight, FacebookWeight, HackerNewsWeight, GoogleNewsWeight, TwitterWeight
-		)
-		VALUES (?,?,?,?,?,?,?,?)`,
-		user.ID, user.Username, user.Password,
-		pw.Reddit, pw.Facebook, pw.HackerNews, pw.GoogleNews, pw.Twitter)
+		INSERT INTO UserInfo (UserID, Username, Password)
+		VALUES (?,?,?)`,
+		user.ID, user.Username, user.Password)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	values := []string{}
-	args := make([]interface{}, 0)
-	for name, group := range user.RssGroups {
-		values = append(values, "(?,?,?,?)")
-		args = append(args, user.Username, strings.Join(group, ","), user.PostWeights.RSS[name], name)
+	if err = d.setSourceWeights(tx, user.Username, weightsFromModel(user.PostWeights)); err != nil {
+		return err
 	}
 
-	_, err = tx.Exec(`
-		INSERT INTO Rss (Username, Feeds, Weight, Name)
-		VALUES `+strings.Join(values, ","), args...)
-	if err != nil {
-		log.Println(err)
-		return err
+	if len(user.RssGroups) > 0 {
+		args := make([]interface{}, 0)
+		for name, group := range user.RssGroups {
+			args = append(args, user.Username, strings.Join(group, ","), user.PostWeights.RSS[name], name)
+		}
+
+		_, err = tx.Exec(insert("Rss", []string{"Username", "Feeds", "Weight", "Name"}, len(user.RssGroups)), args...)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
 	}
 
 	log.Printf("Successfuly inserted user with ID: %v, and username: %v", user.ID, user.Username)
@@ -116,8 +120,12 @@ func (d *driver) GetRedditOAuthToken(username string) (string, error) {
 
 	var RedditAuthToken string
 	rows.Scan(&RedditAuthToken)
+	if err := d.decrypt(username, tokenField{"RedditAuthToken", &RedditAuthToken}); err != nil {
+		log.Printf("Unable to decrypt reddit token for user %v: %v", username, err)
+		return "", err
+	}
 
-	log.Printf("Successfully got auth token: %v for user %v.", RedditAuthToken, username)
+	log.Printf("Successfully got auth token for user %v.", username)
 	return RedditAuthToken, nil
 }
 
@@ -146,6 +154,11 @@ func (d *driver) GetTwitterSecrets(username string) (string, string, error) {
 
 	var TwitterAuthToken, TwitterSecret string
 	rows.Scan(&TwitterAuthToken, &TwitterSecret)
+	err = d.decrypt(username, tokenField{"TwitterAuthToken", &TwitterAuthToken}, tokenField{"TwitterSecret", &TwitterSecret})
+	if err != nil {
+		log.Printf("Unable to decrypt twitter secrets for user %v: %v", username, err)
+		return "", "", err
+	}
 
 	log.Printf("Successfully twitter token and secret for user %v.", username)
 	return TwitterAuthToken, TwitterSecret, nil
@@ -166,7 +179,6 @@ func (d *driver) GetUser(username string) (models.User, bool, error) {
 	rows, err := d.db.Query(`
 		SELECT UserID, UserInfo.Username, Password, TwitterUsername, TwitterAuthToken, TwitterSecret,
 			RedditUsername, RedditAuthToken, RedditRefreshToken, FacebookUsername, FacebookAuthToken,
-			RedditWeight, FacebookWeight, HackerNewsWeight, GoogleNewsWeight, TwitterWeight,
 			Rss.Feeds, Rss.Weight, Rss.Name
 		FROM UserInfo
 		LEFT JOIN Rss ON UserInfo.Username=Rss.Username
@@ -197,11 +209,6 @@ func (d *driver) GetUser(username string) (models.User, bool, error) {
 			&user.RedditRefreshToken,
 			&user.FacebookUsername,
 			&user.FacebookAuthToken,
-			&user.PostWeights.Reddit,
-			&user.PostWeights.Facebook,
-			&user.PostWeights.HackerNews,
-			&user.PostWeights.GoogleNews,
-			&user.PostWeights.Twitter,
 			&rssFeeds, &rssWeight, &rssName,
 		)
 
@@ -221,6 +228,24 @@ func (d *driver) GetUser(username string) (models.User, bool, error) {
 		return user, false, nil
 	}
 
+	err = d.decrypt(username,
+		tokenField{"TwitterAuthToken", &user.TwitterAuthToken},
+		tokenField{"TwitterSecret", &user.TwitterSecret},
+		tokenField{"RedditAuthToken", &user.RedditAuthToken},
+		tokenField{"RedditRefreshToken", &user.RedditRefreshToken},
+		tokenField{"FacebookAuthToken", &user.FacebookAuthToken},
+	)
+	if err != nil {
+		log.Printf("Unable to decrypt tokens for user %v: %v", username, err)
+		return user, true, err
+	}
+
+	weights, err := d.GetWeights(username)
+	if err != nil {
+		return user, true, err
+	}
+	applyWeights(&user.PostWeights, weights)
+
 	return user, true, nil
 }
 
@@ -250,42 +275,39 @@ func (d *driver) UsernameExists(username string) (bool, error) {
 	return true, nil
 }
 
-func (d *driver) UpdateWeights(username string, weights models.Weights) bool {
+func (d *driver) UpdateWeights(username string, weights models.Weights) (updated bool) {
 	log.Printf("Preparing to insert weights into db for user: %v", username)
-	var err error
 	tx, err := d.db.Begin()
 	if err != nil {
 		log.Println(err)
 		return false
 	}
+	// A transaction that fails to commit fails the whole update
 	defer func() {
-		if err == nil {
-			tx.Commit()
-		} else {
+		if err != nil {
 			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			log.Println(err)
+			updated = false
 		}
 	}()
 
-	res, err := tx.Exec(`
-		UPDATE UserInfo SET RedditWeight=?, FacebookWeight=?, HackerNewsWeight=?, GoogleNewsWeight=?, TwitterWeight=? WHERE Username=?
-	`, weights.Reddit, weights.Facebook, weights.HackerNews, weights.GoogleNews, weights.Twitter, username)
+	exists, err := userExists(tx, username)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
-
-	n, err := res.RowsAffected()
-	if err != nil {
-		log.Println(err)
+	if !exists {
+		log.Printf("Could not find user %v when updating weights", username)
 		return false
 	}
-	if n == 0 {
-		log.Println("Could not find user %v when updating weights", username)
+
+	if err = d.setSourceWeights(tx, username, weightsFromModel(weights)); err != nil {
 		return false
 	}
 
 	for name, weight := range weights.RSS {
-		res, err = tx.Exec(`
+		_, err = tx.Exec(`
 			UPDATE Rss SET Weight=? WHERE Username=? AND Name=?
 		`, weight, username, name)
 		if err != nil {
@@ -307,6 +329,12 @@ func (d *driver) UpdateRedditAccount(username, redditUser, authToken, refreshTok
 		return false
 	}
 
+	err = d.encrypt(username, tokenField{"RedditAuthToken", &authToken}, tokenField{"RedditRefreshToken", &refreshToken})
+	if err != nil {
+		log.Printf("Unable to encrypt reddit tokens: %v", err)
+		return false
+	}
+
 	res, err := stmt.Exec(redditUser, authToken, refreshToken, username)
 	if err != nil {
 		log.Println(err)
@@ -333,6 +361,12 @@ func (d *driver) UpdateTwitterAccount(username, twitterUser, authToken, secret s
 		return false
 	}
 
+	err = d.encrypt(username, tokenField{"TwitterAuthToken", &authToken}, tokenField{"TwitterSecret", &secret})
+	if err != nil {
+		log.Printf("Unable to encrypt twitter tokens: %v", err)
+		return false
+	}
+
 	res, err := stmt.Exec(twitterUser, authToken, secret, username)
 	if err != nil {
 		log.Println(err)
@@ -359,6 +393,11 @@ func (d *driver) UpdateFacebookAccount(username, facebookUser, authToken string)
 		return false
 	}
 
+	if err := d.encrypt(username, tokenField{"FacebookAuthToken", &authToken}); err != nil {
+		log.Printf("Unable to encrypt facebook token: %v", err)
+		return false
+	}
+
 	res, err := stmt.Exec(facebookUser, authToken, username)
 	if err != nil {
 		log.Println(err)
@@ -388,6 +427,11 @@ func (d *driver) UpdateOAuthToken(username, token, expiry string) bool {
 		return false
 	}
 
+	if err := d.encrypt(username, tokenField{"RedditAuthToken", &token}); err != nil {
+		log.Printf("Unable to encrypt oauth token: %v", err)
+		return false
+	}
+
 	res, err := stmt.Exec(token, expiry, username)
 	if err != nil {
 		log.Println(err)
@@ -404,68 +448,47 @@ func (d *driver) UpdateOAuthToken(username, token, expiry string) bool {
 	return n > 0
 }
 
-func (d *driver) UpdateRssFeeds(username string, feeds map[string][]string) error {
+func (d *driver) UpdateRssFeeds(username string, feeds map[string][]string) (err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
+	// A transaction that fails to commit fails the whole update
 	defer func() {
 		if err == nil {
-			tx.Commit()
+			err = tx.Commit()
 		} else {
 			tx.Rollback()
 		}
 	}()
 
-	caseArgs := make([]interface{}, 0)
-	caseVals := []string{}
-	whereArgs := make([]interface{}, 0)
-	whereVals := []string{}
-	for name, feeds := range feeds {
-		caseArgs = append(caseArgs, username, name, strings.Join(feeds, ","))
-		caseVals = append(caseVals, "WHEN ? || ? THEN ?")
-		whereArgs = append(whereArgs, username, name)
-		whereVals = append(whereVals, "? || ?")
-	}
-    if len(caseVals) > 0 {
-        _, err = tx.Exec(`
-            UPDATE Rss SET Feeds = CASE Username || Name
-                `+strings.Join(caseVals, " ")+`
-                ELSE Feeds
-                END
-            WHERE Username || Name IN (`+strings.Join(whereVals, ",")+`)
-        `, append(caseArgs, whereArgs...)...)
-        if err != nil {
-            return err
-        }
-    }
-
-	valuesVals := []string{}
-	valuesArgs := make([]interface{}, 0)
+	// Update the feeds of existing groups without touching their weights and add any new groups
+	args := make([]interface{}, 0)
 	for name, feeds := range feeds {
-		valuesArgs = append(valuesArgs, username, strings.Join(feeds, ","), name)
-		valuesVals = append(valuesVals, "(?,?,?)")
-	}
-    if len(valuesVals) > 0 {
-        _, err = tx.Exec(`
-            INSERT OR IGNORE INTO Rss (Username, Feeds, Name)
-            VALUES `+strings.Join(valuesVals, ","), valuesArgs...)
-        if err != nil {
-            return err
-        }
-    }
+		args = append(args, username, strings.Join(feeds, ","), name)
+	}
+	if len(feeds) > 0 {
+		_, err = tx.Exec(d.dialect.upsert("Rss", []string{"Username", "Name"},
+			[]string{"Username", "Feeds", "Name"}, []string{"Feeds"}, len(feeds)), args...)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
 
+	// Remove every group that is no longer present
 	values := []string{}
-	args := make([]interface{}, 0)
+	args = []interface{}{username}
 	for name := range feeds {
 		values = append(values, "?")
 		args = append(args, name)
 	}
 
-	_, err = tx.Exec(`
-		DELETE FROM Rss WHERE NOT Name IN (`+strings.Join(values, ",")+`) AND Username=?
-	`, append(args, username)...)
-	if err != nil {
+	query := "DELETE FROM Rss WHERE Username=?"
+	if len(values) > 0 {
+		query += " AND Name NOT IN (" + strings.Join(values, ",") + ")"
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
 		log.Println(err)
 		return err
 	}
@@ -491,13 +514,23 @@ func New(config Config) (*driver, error) {
 		dbDriver = config.DatabaseDriver
 	}
 
+	dialect, err := dialectFor(dbDriver)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := sql.Open(dbDriver, dbPath)
 	if err != nil {
 		return nil, err
 	}
 
 	// Construct new driver object with database file name
-	d := &driver{db}
+	d := &driver{db: db, dbDriver: dbDriver, dialect: dialect, keyring: config.Keyring}
 
 	return d, nil
 }
+
+// Checks that the database can be reached
+func (d *driver) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
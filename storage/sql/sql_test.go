@@ -1,19 +1,26 @@
 package sql
 
 import (
+	"context"
+	"io/ioutil"
 	"log"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/iced-mocha/core/storage"
+	"github.com/iced-mocha/shared/models"
 	"github.com/stretchr/testify/suite"
 )
 
-const (
-	testDatabaseName = "test.db"
-)
-
 type DriverTestSuite struct {
 	suite.Suite
 	d *driver
+
+	// The database to run against, an empty driver name uses a temporary sqlite database
+	driverName string
+	dsn        string
+	dbFile     string
 }
 
 // Deletes all data from database for testing
@@ -21,15 +28,43 @@ func (suite *DriverTestSuite) WipeData() {
 	// Query to delete all entries from UserInfo table
 	_, err := suite.d.db.Exec("DELETE FROM UserInfo")
 	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Rss")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Weights")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Preferences")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Seen")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Saved")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM MuteFilters")
+	suite.Nil(err)
+	_, err = suite.d.db.Exec("DELETE FROM Boosts")
+	suite.Nil(err)
 }
 
 func (suite *DriverTestSuite) SetupSuite() {
 	var err error
+	if suite.driverName == "" {
+		f, err := ioutil.TempFile("", "driver")
+		suite.Nil(err)
+		suite.Nil(f.Close())
+		suite.dbFile = f.Name()
+		suite.driverName, suite.dsn = "sqlite3", suite.dbFile
+	}
 
-	// Note it is assumed an initialized test.db file exists within the test/ directory
-	suite.d, err = New(Config{DatabasePath: testDatabaseName})
+	suite.d, err = New(Config{DatabasePath: suite.dsn, DatabaseDriver: suite.driverName})
 	if err != nil {
-		log.Printf("Unable to create db object: %v\n", err)
+		log.Fatalf("Unable to create db object: %v\n", err)
+	}
+
+	suite.Nil(suite.d.MigrateUp())
+}
+
+func (suite *DriverTestSuite) TearDownSuite() {
+	if suite.dbFile != "" {
+		suite.Nil(os.Remove(suite.dbFile))
 	}
 }
 
@@ -50,6 +85,299 @@ func (suite *DriverTestSuite) TestUsernameExists() {
 	suite.False(exists)
 }
 
+func (suite *DriverTestSuite) TestPing() {
+	suite.Nil(suite.d.Ping(context.Background()))
+}
+
+func (suite *DriverTestSuite) TestWeights() {
+	user := models.User{
+		ID:       "id",
+		Username: "jgore",
+		Password: "hash",
+		PostWeights: models.Weights{
+			Reddit:  20.0,
+			Twitter: 10.0,
+			RSS:     map[string]float64{"news": 5.0},
+		},
+		RssGroups: map[string][]string{"news": []string{"http://example.com/rss"}},
+	}
+	suite.Nil(suite.d.InsertUser(user))
+
+	weights, err := suite.d.GetWeights("jgore")
+	suite.Nil(err)
+	suite.Equal(20.0, weights["reddit"])
+	suite.Equal(10.0, weights["twitter"])
+
+	// Sources without a column of their own can be given weights
+	suite.Nil(suite.d.UpdateSourceWeights("jgore", map[string]float64{"new-client": 7.5, "reddit": 1.0}))
+	weights, err = suite.d.GetWeights("jgore")
+	suite.Nil(err)
+	suite.Equal(7.5, weights["new-client"])
+	suite.Equal(1.0, weights["reddit"])
+	suite.Equal(10.0, weights["twitter"])
+
+	// Updating the model weights leaves the other sources alone
+	user.PostWeights.Facebook = 3.0
+	suite.True(suite.d.UpdateWeights("jgore", user.PostWeights))
+	u, exists, err := suite.d.GetUser("jgore")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal(3.0, u.PostWeights.Facebook)
+	suite.Equal(20.0, u.PostWeights.Reddit)
+	suite.Equal(5.0, u.PostWeights.RSS["news"])
+
+	weights, err = suite.d.GetWeights("jgore")
+	suite.Nil(err)
+	suite.Equal(7.5, weights["new-client"])
+
+	suite.NotNil(suite.d.UpdateSourceWeights("missing", map[string]float64{"reddit": 1.0}))
+	suite.False(suite.d.UpdateWeights("missing", user.PostWeights))
+}
+
+func (suite *DriverTestSuite) TestUpdateRssFeeds() {
+	user := models.User{
+		ID:       "id",
+		Username: "jgore",
+		Password: "hash",
+		PostWeights: models.Weights{
+			RSS: map[string]float64{"news": 5.0, "sports": 3.0},
+		},
+		RssGroups: map[string][]string{
+			"news":   []string{"http://example.com/news"},
+			"sports": []string{"http://example.com/sports"},
+		},
+	}
+	suite.Nil(suite.d.InsertUser(user))
+
+	// Existing groups keep their weights, removed groups are deleted and new groups are added
+	suite.Nil(suite.d.UpdateRssFeeds("jgore", map[string][]string{
+		"news": []string{"http://example.com/news", "http://example.com/more"},
+		"tech": []string{},
+	}))
+
+	u, exists, err := suite.d.GetUser("jgore")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal(map[string][]string{
+		"news": []string{"http://example.com/news", "http://example.com/more"},
+		"tech": []string{},
+	}, u.RssGroups)
+	suite.Equal(5.0, u.PostWeights.RSS["news"])
+
+	// Feeds of other users are untouched
+	other := user
+	other.ID, other.Username = "other", "other"
+	suite.Nil(suite.d.InsertUser(other))
+	suite.Nil(suite.d.UpdateRssFeeds("jgore", map[string][]string{}))
+
+	u, _, err = suite.d.GetUser("jgore")
+	suite.Nil(err)
+	suite.Empty(u.RssGroups)
+	u, _, err = suite.d.GetUser("other")
+	suite.Nil(err)
+	suite.Len(u.RssGroups, 2)
+}
+
+func (suite *DriverTestSuite) TestRssGroups() {
+	user := models.User{
+		ID:          "id",
+		Username:    "jgore",
+		Password:    "hash",
+		PostWeights: models.Weights{RSS: map[string]float64{"news": 5.0}},
+		RssGroups:   map[string][]string{"news": []string{"http://example.com/news"}},
+	}
+	suite.Nil(suite.d.InsertUser(user))
+
+	group, exists, err := suite.d.GetRssGroup("jgore", "news")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal(storage.RssGroup{Name: "news", Feeds: []string{"http://example.com/news"}, Weight: 5.0}, group)
+
+	_, exists, err = suite.d.GetRssGroup("jgore", "tech")
+	suite.Nil(err)
+	suite.False(exists)
+
+	// Putting a group creates it or replaces both its feeds and its weight
+	tech := storage.RssGroup{Name: "tech", Feeds: []string{}, Weight: 7.5}
+	suite.Nil(suite.d.PutRssGroup("jgore", tech))
+	group, exists, err = suite.d.GetRssGroup("jgore", "tech")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal(tech, group)
+
+	news := storage.RssGroup{Name: "news", Feeds: []string{"http://example.com/a", "http://example.com/b"}, Weight: 2.0}
+	suite.Nil(suite.d.PutRssGroup("jgore", news))
+
+	// The weights of groups are the ones used for ranking
+	u, _, err := suite.d.GetUser("jgore")
+	suite.Nil(err)
+	suite.Equal(map[string][]string{"news": news.Feeds, "tech": []string{}}, u.RssGroups)
+	suite.Equal(map[string]float64{"news": 2.0, "tech": 7.5}, u.PostWeights.RSS)
+
+	deleted, err := suite.d.DeleteRssGroup("jgore", "news")
+	suite.Nil(err)
+	suite.True(deleted)
+	deleted, err = suite.d.DeleteRssGroup("jgore", "news")
+	suite.Nil(err)
+	suite.False(deleted)
+	_, exists, err = suite.d.GetRssGroup("jgore", "news")
+	suite.Nil(err)
+	suite.False(exists)
+}
+
+func (suite *DriverTestSuite) TestRankingStrategy() {
+	// Users that do not exist cannot pick a strategy
+	suite.NotNil(suite.d.UpdateRankingStrategy("jgore", "chronological"))
+
+	suite.Nil(suite.d.InsertUser(models.User{ID: "1", Username: "jgore", Password: "hash"}))
+
+	strategy, err := suite.d.GetRankingStrategy("jgore")
+	suite.Nil(err)
+	suite.Equal("", strategy)
+
+	suite.Nil(suite.d.UpdateRankingStrategy("jgore", "chronological"))
+	suite.Nil(suite.d.UpdateRankingStrategy("jgore", "popularity"))
+	strategy, err = suite.d.GetRankingStrategy("jgore")
+	suite.Nil(err)
+	suite.Equal("popularity", strategy)
+}
+
+func (suite *DriverTestSuite) TestSeen() {
+	now := time.Unix(1520000000, 0)
+	suite.Nil(suite.d.MarkSeen("jgore", []string{"a", "b"}, now.Add(-48*time.Hour)))
+	suite.Nil(suite.d.MarkSeen("jgore", []string{"b", "c"}, now))
+	suite.Nil(suite.d.MarkSeen("other", []string{"d"}, now))
+	suite.Nil(suite.d.MarkSeen("jgore", []string{}, now))
+
+	// Seeing a post again counts from the latest time it was seen
+	seen, err := suite.d.GetSeen("jgore", now.Add(-time.Hour))
+	suite.Nil(err)
+	suite.Equal(map[string]bool{"b": true, "c": true}, seen)
+
+	seen, err = suite.d.GetSeen("jgore", now.Add(-72*time.Hour))
+	suite.Nil(err)
+	suite.Equal(map[string]bool{"a": true, "b": true, "c": true}, seen)
+
+	suite.Nil(suite.d.DeleteSeen("jgore", now.Add(-time.Hour)))
+	seen, err = suite.d.GetSeen("jgore", time.Unix(0, 0))
+	suite.Nil(err)
+	suite.Equal(map[string]bool{"b": true, "c": true}, seen)
+
+	// Posts seen by other users are untouched
+	seen, err = suite.d.GetSeen("other", time.Unix(0, 0))
+	suite.Nil(err)
+	suite.Equal(map[string]bool{"d": true}, seen)
+}
+
+func (suite *DriverTestSuite) TestSavedPosts() {
+	now := time.Unix(1520000000, 0)
+	post := models.Post{
+		ID: "a", Date: now.Add(-time.Hour), Author: "jgore", Title: "A post", Content: "Words",
+		HeroImg: "https://example.com/a.png", PostLink: "https://example.com/a", Platform: "reddit",
+		Score: 42, Subreddit: "golang",
+	}
+	suite.Nil(suite.d.SavePost("jgore", post, now))
+	suite.Nil(suite.d.SavePost("jgore", models.Post{ID: "b"}, now))
+	suite.Nil(suite.d.SavePost("jgore", models.Post{ID: "c"}, now.Add(time.Minute)))
+	suite.Nil(suite.d.SavePost("other", models.Post{ID: "d"}, now))
+
+	// Saving a post again updates it without moving it
+	post.Score = 50
+	suite.Nil(suite.d.SavePost("jgore", post, now.Add(time.Hour)))
+
+	posts, err := suite.d.GetSavedPosts("jgore", nil, 2)
+	suite.Nil(err)
+	suite.Len(posts, 2)
+	suite.Equal("c", posts[0].ID)
+	suite.Equal(now.Add(time.Minute), posts[0].SavedAt)
+	suite.True(posts[0].Date.IsZero())
+	post.Date = post.Date.Local()
+	suite.Equal(post, posts[1].Post)
+	suite.Equal(now, posts[1].SavedAt)
+
+	// Posts saved at the same time are ordered by id
+	posts, err = suite.d.GetSavedPosts("jgore", &storage.SavedCursor{SavedAt: posts[1].SavedAt, PostID: posts[1].ID}, 2)
+	suite.Nil(err)
+	suite.Len(posts, 1)
+	suite.Equal("b", posts[0].ID)
+
+	deleted, err := suite.d.DeleteSavedPost("jgore", "c")
+	suite.Nil(err)
+	suite.True(deleted)
+	deleted, err = suite.d.DeleteSavedPost("jgore", "c")
+	suite.Nil(err)
+	suite.False(deleted)
+
+	posts, err = suite.d.GetSavedPosts("jgore", nil, 10)
+	suite.Nil(err)
+	suite.Len(posts, 2)
+	posts, err = suite.d.GetSavedPosts("other", nil, 10)
+	suite.Nil(err)
+	suite.Len(posts, 1)
+}
+
+func (suite *DriverTestSuite) TestMuteFilters() {
+	filters, err := suite.d.GetMuteFilters("jgore")
+	suite.Nil(err)
+	suite.Empty(filters)
+
+	keyword := storage.MuteFilter{ID: "1", Type: "keyword", Value: "crypto"}
+	domain := storage.MuteFilter{ID: "2", Type: "domain", Value: "example.com"}
+	suite.Nil(suite.d.InsertMuteFilter("jgore", keyword))
+	suite.Nil(suite.d.InsertMuteFilter("jgore", domain))
+	suite.Nil(suite.d.InsertMuteFilter("other", keyword))
+	// Ids are unique per user
+	suite.NotNil(suite.d.InsertMuteFilter("jgore", keyword))
+
+	filters, err = suite.d.GetMuteFilters("jgore")
+	suite.Nil(err)
+	suite.Equal([]storage.MuteFilter{domain, keyword}, filters)
+
+	deleted, err := suite.d.DeleteMuteFilter("jgore", "1")
+	suite.Nil(err)
+	suite.True(deleted)
+	deleted, err = suite.d.DeleteMuteFilter("jgore", "1")
+	suite.Nil(err)
+	suite.False(deleted)
+
+	filters, err = suite.d.GetMuteFilters("jgore")
+	suite.Nil(err)
+	suite.Equal([]storage.MuteFilter{domain}, filters)
+	filters, err = suite.d.GetMuteFilters("other")
+	suite.Nil(err)
+	suite.Equal([]storage.MuteFilter{keyword}, filters)
+}
+
+func (suite *DriverTestSuite) TestBoosts() {
+	boosts, err := suite.d.GetBoosts("jgore")
+	suite.Nil(err)
+	suite.Empty(boosts)
+
+	keyword := storage.Boost{ID: "1", Type: "keyword", Value: "golang", Multiplier: 2.5}
+	domain := storage.Boost{ID: "2", Type: "domain", Value: "example.com", Multiplier: 0.5}
+	suite.Nil(suite.d.InsertBoost("jgore", keyword))
+	suite.Nil(suite.d.InsertBoost("jgore", domain))
+	suite.Nil(suite.d.InsertBoost("other", keyword))
+
+	boosts, err = suite.d.GetBoosts("jgore")
+	suite.Nil(err)
+	suite.Equal([]storage.Boost{domain, keyword}, boosts)
+
+	deleted, err := suite.d.DeleteBoost("jgore", "2")
+	suite.Nil(err)
+	suite.True(deleted)
+	deleted, err = suite.d.DeleteBoost("jgore", "2")
+	suite.Nil(err)
+	suite.False(deleted)
+
+	boosts, err = suite.d.GetBoosts("jgore")
+	suite.Nil(err)
+	suite.Equal([]storage.Boost{keyword}, boosts)
+	boosts, err = suite.d.GetBoosts("other")
+	suite.Nil(err)
+	suite.Equal([]storage.Boost{keyword}, boosts)
+}
+
 func (suite *DriverTestSuite) TestNew() {
 	// Creating a basic driver should work so long as the file is there
 	_, err := New(Config{})
@@ -61,3 +389,14 @@ func (suite *DriverTestSuite) TestNew() {
 func TestSuite(t *testing.T) {
 	suite.Run(t, new(DriverTestSuite))
 }
+
+// Runs the same suite against mysql when TEST_MYSQL_DSN is set, see scripts/testMySQL.sh. CI always sets it.
+func TestMySQLSuite(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" && os.Getenv("CI") != "" {
+		t.Fatal("TEST_MYSQL_DSN must be set in CI")
+	} else if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN not set, skipping mysql tests")
+	}
+	suite.Run(t, &DriverTestSuite{driverName: "mysql", dsn: dsn})
+}
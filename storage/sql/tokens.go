@@ -0,0 +1,119 @@
+package sql
+
+import (
+	"errors"
+	"log"
+
+	"github.com/iced-mocha/core/secrets"
+)
+
+// The columns of UserInfo that hold tokens of linked accounts
+var tokenColumns = []string{"TwitterAuthToken", "TwitterSecret", "RedditAuthToken", "RedditRefreshToken", "FacebookAuthToken"}
+
+// A token along with the column of UserInfo it is stored in
+type tokenField struct {
+	column string
+	value  *string
+}
+
+// Produces the additional data tokens are encrypted with, binding them to the user and column they are stored in
+func tokenAAD(username, column string) []byte {
+	return []byte(username + "\x00" + column)
+}
+
+// Encrypts each of the given tokens of the user in place with the drivers keyring
+func (d *driver) encrypt(username string, tokens ...tokenField) error {
+	if d.keyring == nil {
+		return nil
+	}
+
+	for _, t := range tokens {
+		encrypted, err := d.keyring.Encrypt(*t.value, tokenAAD(username, t.column))
+		if err != nil {
+			return err
+		}
+		*t.value = encrypted
+	}
+	return nil
+}
+
+// Decrypts each of the given tokens of the user in place with the drivers keyring
+func (d *driver) decrypt(username string, tokens ...tokenField) error {
+	for _, t := range tokens {
+		if d.keyring == nil {
+			// Values stored in plaintext can still be read without a keyring
+			if secrets.IsEncrypted(*t.value) {
+				return errors.New("token is encrypted but no keyring was configured")
+			}
+			continue
+		}
+
+		decrypted, err := d.keyring.Decrypt(*t.value, tokenAAD(username, t.column))
+		if err != nil {
+			return err
+		}
+		*t.value = decrypted
+	}
+	return nil
+}
+
+// Re-encrypts every stored token that is in plaintext, was encrypted with a key other than the primary
+// key of the keyring or was not bound to its user and column. Returns the number of users that were updated.
+func (d *driver) ReencryptTokens() (int, error) {
+	if d.keyring == nil {
+		return 0, errors.New("no keyring configured")
+	}
+
+	rows, err := d.db.Query(`
+		SELECT Username, TwitterAuthToken, TwitterSecret, RedditAuthToken, RedditRefreshToken, FacebookAuthToken
+		FROM UserInfo`)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read everything first as sqlite cannot update a table while we are reading from it
+	updates := make(map[string][]string)
+	for rows.Next() {
+		var username string
+		var refreshToken NullString
+		tokens := make([]string, len(tokenColumns))
+		if err := rows.Scan(&username, &tokens[0], &tokens[1], &tokens[2], &refreshToken, &tokens[4]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tokens[3] = refreshToken.String
+
+		for _, token := range tokens {
+			if d.keyring.NeedsRotation(token) {
+				updates[username] = tokens
+				break
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for username, tokens := range updates {
+		for i, column := range tokenColumns {
+			aad := tokenAAD(username, column)
+			if tokens[i], err = d.keyring.Decrypt(tokens[i], aad); err != nil {
+				return 0, err
+			}
+			if tokens[i], err = d.keyring.Encrypt(tokens[i], aad); err != nil {
+				return 0, err
+			}
+		}
+
+		_, err := d.db.Exec(`
+			UPDATE UserInfo SET TwitterAuthToken=?, TwitterSecret=?, RedditAuthToken=?, RedditRefreshToken=?, FacebookAuthToken=?
+			WHERE Username=?`, tokens[0], tokens[1], tokens[2], tokens[3], tokens[4], username)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Re-encrypted tokens for user %v", username)
+	}
+
+	return len(updates), nil
+}
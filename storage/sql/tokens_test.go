@@ -0,0 +1,155 @@
+package sql
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iced-mocha/core/secrets"
+	"github.com/iced-mocha/shared/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokensTestSuite struct {
+	suite.Suite
+	dbFile string
+	oldKey []byte
+	newKey []byte
+}
+
+func (suite *TokensTestSuite) SetupSuite() {
+	log.SetOutput(ioutil.Discard)
+
+	var err error
+	suite.oldKey, err = secrets.NewKey()
+	suite.Nil(err)
+	suite.newKey, err = secrets.NewKey()
+	suite.Nil(err)
+}
+
+func (suite *TokensTestSuite) SetupTest() {
+	f, err := ioutil.TempFile("", "tokens")
+	suite.Nil(err)
+	suite.Nil(f.Close())
+	suite.dbFile = f.Name()
+}
+
+func (suite *TokensTestSuite) TearDownTest() {
+	suite.Nil(os.Remove(suite.dbFile))
+}
+
+// Creates a driver on the test database using a keyring with the given keys, the first being the primary
+func (suite *TokensTestSuite) newDriver(ids ...string) *driver {
+	keys := map[string][]byte{"old": suite.oldKey, "new": suite.newKey}
+	var keyring *secrets.Keyring
+	if len(ids) > 0 {
+		ring := make(map[string][]byte)
+		for _, id := range ids {
+			ring[id] = keys[id]
+		}
+
+		var err error
+		keyring, err = secrets.NewKeyring(ids[0], ring)
+		suite.Nil(err)
+	}
+
+	d, err := New(Config{DatabasePath: suite.dbFile, Keyring: keyring})
+	suite.Nil(err)
+	suite.Nil(d.MigrateUp())
+	return d
+}
+
+// Reads the raw reddit tokens as stored in the database
+func (suite *TokensTestSuite) storedRedditTokens(d *driver) (string, string) {
+	var token, refresh string
+	suite.Nil(d.db.QueryRow("SELECT RedditAuthToken, RedditRefreshToken FROM UserInfo WHERE Username='jgore'").Scan(&token, &refresh))
+	return token, refresh
+}
+
+func (suite *TokensTestSuite) insertUser(d *driver) {
+	suite.Nil(d.InsertUser(models.User{ID: "id", Username: "jgore", Password: "hash"}))
+}
+
+func (suite *TokensTestSuite) TestEncryptedAtRest() {
+	d := suite.newDriver("old")
+	suite.insertUser(d)
+	suite.True(d.UpdateRedditAccount("jgore", "redditor", "bearer", "refresh"))
+	suite.True(d.UpdateTwitterAccount("jgore", "tweeter", "token", "secret"))
+	suite.True(d.UpdateFacebookAccount("jgore", "facebooker", "fbtoken"))
+
+	token, refresh := suite.storedRedditTokens(d)
+	suite.True(secrets.IsEncrypted(token))
+	suite.True(secrets.IsEncrypted(refresh))
+	suite.False(strings.Contains(token, "bearer"))
+
+	user, exists, err := d.GetUser("jgore")
+	suite.Nil(err)
+	suite.True(exists)
+	suite.Equal("redditor", user.RedditUsername)
+	suite.Equal("bearer", user.RedditAuthToken)
+	suite.Equal("refresh", user.RedditRefreshToken)
+	suite.Equal("token", user.TwitterAuthToken)
+	suite.Equal("secret", user.TwitterSecret)
+	suite.Equal("fbtoken", user.FacebookAuthToken)
+
+	token, err = d.GetRedditOAuthToken("jgore")
+	suite.Nil(err)
+	suite.Equal("bearer", token)
+
+	// Without the key the tokens cannot be read
+	_, _, err = suite.newDriver().GetUser("jgore")
+	suite.NotNil(err)
+
+	// Tokens are bound to their user and column so they cannot be moved to another
+	suite.Nil(d.InsertUser(models.User{ID: "other", Username: "mallory", Password: "hash"}))
+	_, err = d.db.Exec("UPDATE UserInfo SET RedditAuthToken=? WHERE Username='mallory'", refresh)
+	suite.Nil(err)
+	_, err = d.GetRedditOAuthToken("mallory")
+	suite.NotNil(err)
+	_, err = d.db.Exec("UPDATE UserInfo SET RedditAuthToken=? WHERE Username='jgore'", refresh)
+	suite.Nil(err)
+	_, err = d.GetRedditOAuthToken("jgore")
+	suite.NotNil(err)
+}
+
+func (suite *TokensTestSuite) TestReencrypt() {
+	// Tokens stored before encryption was enabled
+	d := suite.newDriver()
+	suite.insertUser(d)
+	suite.True(d.UpdateRedditAccount("jgore", "redditor", "bearer", "refresh"))
+
+	d = suite.newDriver("old")
+	n, err := d.ReencryptTokens()
+	suite.Nil(err)
+	suite.Equal(1, n)
+	token, _ := suite.storedRedditTokens(d)
+	suite.True(strings.HasPrefix(token, "enc:v2:old:"))
+
+	// Rotate to the new key, tokens under the old key are still readable until re-encrypted
+	d = suite.newDriver("new", "old")
+	user, _, err := d.GetUser("jgore")
+	suite.Nil(err)
+	suite.Equal("bearer", user.RedditAuthToken)
+
+	n, err = d.ReencryptTokens()
+	suite.Nil(err)
+	suite.Equal(1, n)
+	token, _ = suite.storedRedditTokens(d)
+	suite.True(strings.HasPrefix(token, "enc:v2:new:"))
+
+	// Nothing left to rotate, and the old key can be dropped
+	n, err = d.ReencryptTokens()
+	suite.Nil(err)
+	suite.Equal(0, n)
+
+	user, _, err = suite.newDriver("new").GetUser("jgore")
+	suite.Nil(err)
+	suite.Equal("bearer", user.RedditAuthToken)
+	suite.Equal("refresh", user.RedditRefreshToken)
+}
+
+func TestTokensTestSuite(t *testing.T) {
+	suite.Run(t, new(TokensTestSuite))
+}
@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/iced-mocha/shared/models"
+)
+
+// Weights are stored per (user, source) so new clients get weights without schema changes.
+// models.Weights is still used by the api, these helpers convert between the two.
+func weightsFromModel(weights models.Weights) map[string]float64 {
+	return map[string]float64{
+		"reddit":      weights.Reddit,
+		"facebook":    weights.Facebook,
+		"hacker-news": weights.HackerNews,
+		"google-news": weights.GoogleNews,
+		"twitter":     weights.Twitter,
+	}
+}
+
+func applyWeights(weights *models.Weights, sources map[string]float64) {
+	weights.Reddit = sources["reddit"]
+	weights.Facebook = sources["facebook"]
+	weights.HackerNews = sources["hacker-news"]
+	weights.GoogleNews = sources["google-news"]
+	weights.Twitter = sources["twitter"]
+}
+
+// Gets the weight of every source the user has set, keyed by source name
+func (d *driver) GetWeights(username string) (map[string]float64, error) {
+	rows, err := d.db.Query("SELECT Source, Weight FROM Weights WHERE Username=?", username)
+	if err != nil {
+		log.Printf("Unable to get weights for user %v: %v", username, err)
+		return nil, err
+	}
+	// This is need to prevent database locking
+	defer rows.Close()
+
+	weights := make(map[string]float64)
+	for rows.Next() {
+		var source string
+		var weight float64
+		if err := rows.Scan(&source, &weight); err != nil {
+			return nil, err
+		}
+		weights[source] = weight
+	}
+
+	return weights, rows.Err()
+}
+
+// Sets the weight of each of the given sources for the user, other sources are left unchanged
+func (d *driver) UpdateSourceWeights(username string, weights map[string]float64) (err error) {
+	log.Printf("Preparing to update source weights for user: %v", username)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	// A transaction that fails to commit fails the whole update
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	var exists bool
+	if exists, err = userExists(tx, username); err != nil {
+		return err
+	} else if !exists {
+		err = errors.New("No user found in database with given username: " + username)
+		return err
+	}
+
+	err = d.setSourceWeights(tx, username, weights)
+	return err
+}
+
+func userExists(tx *sql.Tx, username string) (bool, error) {
+	rows, err := tx.Query("SELECT 1 FROM UserInfo WHERE Username=?", username)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+func (d *driver) setSourceWeights(tx *sql.Tx, username string, weights map[string]float64) error {
+	if len(weights) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0)
+	for source, weight := range weights {
+		args = append(args, username, source, weight)
+	}
+
+	_, err := tx.Exec(d.dialect.upsert("Weights", []string{"Username", "Source"},
+		[]string{"Username", "Source", "Weight"}, []string{"Weight"}, len(weights)), args...)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}